@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"github.com/j-keck/plog"
 	"github.com/j-keck/zfs-snap-diff/pkg/config"
 	"github.com/j-keck/zfs-snap-diff/pkg/fs"
+	"github.com/j-keck/zfs-snap-diff/pkg/scheduler"
 	"github.com/j-keck/zfs-snap-diff/pkg/webapp"
 	"github.com/j-keck/zfs-snap-diff/pkg/zfs"
 	"os"
@@ -20,13 +22,15 @@ type CliConfig struct {
 	logLocations          bool
 	printVersion          bool
 	listenOnAllInterfaces bool
+	allPools              bool
 }
 
 func main() {
 	zfsSnapDiffBin := os.Args[0]
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "zfs-snap-diff - web application to find older file versions zfs snapshot and zfs snapshot management tool.\n")
-		fmt.Fprintf(os.Stderr, "\nUSAGE:\n  %s [OPTIONS] <ZFS_DATASET_NAME>\n\n", zfsSnapDiffBin)
+		fmt.Fprintf(os.Stderr, "\nUSAGE:\n  %s [OPTIONS] <ZFS_DATASET_NAME> [<ZFS_DATASET_NAME>...]\n", zfsSnapDiffBin)
+		fmt.Fprintf(os.Stderr, "  %s [OPTIONS] -all\n\n", zfsSnapDiffBin)
 		fmt.Fprint(os.Stderr, "OPTIONS:\n")
 		flag.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\nProject home page: https://j-keck.github.io/zfs-snap-diff\n")
@@ -49,12 +53,30 @@ func main() {
 		config.Get.Webserver.ListenIp = "0.0.0.0"
 	}
 
-	datasetName := flag.Arg(0)
-	if len(datasetName) == 0 {
+	if err := zfs.ValidateSnapshotNameTemplate(config.Get.SnapshotNameTemplate); err != nil {
+		log.Warnf("invalid 'snapshot-name-template' in the configuration - %v", err)
+	}
+
+	datasetNames := flag.Args()
+	if cliCfg.allPools {
+		if len(datasetNames) > 0 {
+			log.Warnf("ignore dataset names: '%s' because parameter '-all' was given",
+				strings.Join(datasetNames, ", "))
+		}
+
+		poolNames, err := zfs.AvailablePoolNames()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR:\n\n  %v\n", err)
+			return
+		}
+		datasetNames = poolNames
+	}
+
+	if len(datasetNames) == 0 {
 		if datasetNames, err := zfs.AvailableDatasetNames(); err == nil {
 			fmt.Fprintf(os.Stderr, "\nABORT:\n  paramter <ZFS_DATASET_NAME> missing\n")
 			names := strings.Join(datasetNames, " | ")
-			fmt.Fprintf(os.Stderr, "\nUSAGE:\n  %s [OPTIONS] <ZFS_DATASET_NAME>\n\n", zfsSnapDiffBin)
+			fmt.Fprintf(os.Stderr, "\nUSAGE:\n  %s [OPTIONS] <ZFS_DATASET_NAME> [<ZFS_DATASET_NAME>...]\n\n", zfsSnapDiffBin)
 			fmt.Fprintf(os.Stderr, "  <ZFS_DATASET_NAMES>: %s\n\n", names)
 			fmt.Fprintf(os.Stderr, "For more information use `%s -h`", zfsSnapDiffBin)
 		} else {
@@ -63,8 +85,15 @@ func main() {
 		return
 	}
 
-	if z, err := zfs.NewZFS(datasetName); err == nil {
-		webapp := webapp.NewWebApp(z)
+	if z, err := zfs.NewZFS(datasetNames...); err == nil {
+		sched, err := scheduler.NewSchedulerFromConfig(z)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nABORT:\n  %v\n", err)
+			return
+		}
+		go sched.Run(context.Background())
+
+		webapp := webapp.NewWebApp(z, sched)
 		if err := webapp.Start(); err != nil {
 			fmt.Fprintf(os.Stderr, "\nUnable to start webapp: %v", err)
 		}
@@ -82,6 +111,7 @@ func parseFlags() CliConfig {
 
 	// cli
 	flag.BoolVar(&cliCfg.printVersion, "V", false, "print version and exit")
+	flag.BoolVar(&cliCfg.allPools, "all", false, "serve all imported pools")
 
 	// logging
 	cliCfg.logLevel = plog.Info
@@ -115,9 +145,18 @@ func parseFlags() CliConfig {
 
 	// zfs
 	zfsCfg := &config.Get.ZFS
-	flag.BoolVar(&zfsCfg.UseSudo, "use-sudo", zfsCfg.UseSudo, "use sudo when executing 'zfs' commands")
+	flag.BoolVar(&zfsCfg.UseSudo, "use-sudo", zfsCfg.UseSudo,
+		"use sudo when executing 'zfs' commands - shortcut for '-escalation-command sudo' for all zfs commands")
+	flag.StringVar(&zfsCfg.EscalationCommand, "escalation-command", zfsCfg.EscalationCommand,
+		"command prefix to elevate zfs commands per the configured 'escalate' policy - mutating commands per default (like 'doas' or 'sudo -n -u zfsadmin')")
+	flag.Var(&config.Get.Transport.Wrapper, "transport",
+		"wrapper command to execute zfs commands and snapshot file reads (like 'ssh host' or 'nsenter -t PID -m')")
+	flag.BoolVar(&config.Get.Transport.Shell, "transport-shell", config.Get.Transport.Shell,
+		"the transport wrapper passes the command through a shell (like 'ssh') - quote the arguments")
 	flag.BoolVar(&zfsCfg.MountSnapshots, "mount-snapshots", zfsCfg.MountSnapshots,
 		"mount snapshot (only necessary if it's not mounted by zfs automatically")
+	flag.DurationVar(&zfsCfg.SnapshotCacheTTL.Duration, "snapshot-cache-ttl", zfsCfg.SnapshotCacheTTL.Duration,
+		"max. age of cached snapshot lists - '0s' disables the cache")
 
 	flag.Parse()
 	return *cliCfg
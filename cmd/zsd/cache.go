@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/j-keck/zfs-snap-diff/pkg/fs"
 	"github.com/j-keck/zfs-snap-diff/pkg/scanner"
+	"github.com/j-keck/zfs-snap-diff/pkg/zfs"
 	"os"
 )
 
@@ -42,3 +43,34 @@ func loadCachedFileVersions() ([]scanner.FileVersion, error) {
 	err = json.Unmarshal(b, &versions)
 	return versions, err
 }
+
+func saveSnapshotCache(cache *zfs.SnapshotCache) error {
+	j, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+
+	cacheDir, err := fs.CacheDir()
+	if err != nil {
+		return err
+	}
+
+	_, err = cacheDir.WriteFile("zsd-snapshots.cache", j, 0644)
+	return err
+}
+
+func loadSnapshotCache(cache *zfs.SnapshotCache) error {
+	cacheDir, err := fs.CacheDir()
+	if err != nil {
+		return err
+	}
+
+	b, err := cacheDir.ReadFile("zsd-snapshots.cache")
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to load cached snapshots - %v", err)
+	}
+
+	return json.Unmarshal(b, cache)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
@@ -8,7 +9,10 @@ import (
 	"github.com/j-keck/zfs-snap-diff/pkg/config"
 	"github.com/j-keck/zfs-snap-diff/pkg/diff"
 	"github.com/j-keck/zfs-snap-diff/pkg/fs"
+	"github.com/j-keck/zfs-snap-diff/pkg/retention"
 	"github.com/j-keck/zfs-snap-diff/pkg/scanner"
+	"github.com/j-keck/zfs-snap-diff/pkg/scheduler"
+	"github.com/j-keck/zfs-snap-diff/pkg/transport"
 	"github.com/j-keck/zfs-snap-diff/pkg/zfs"
 	"math"
 	"os"
@@ -25,13 +29,16 @@ type CliConfig struct {
 	printVersion              bool
 	scriptingOutput           bool
 	snapshotTimemachineOutput bool
+	runScheduler              bool
+	query                     string
 }
 
 func main() {
 	zsdBin := os.Args[0]
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "zsd - cli tool to find older versions of a given file in your zfs snapshots.\n\n")
-		fmt.Fprintf(os.Stderr, "USAGE:\n %s [OPTIONS] <FILE> <ACTION>\n\n", zsdBin)
+		fmt.Fprintf(os.Stderr, "USAGE:\n %s [OPTIONS] <FILE> <ACTION>\n", zsdBin)
+		fmt.Fprintf(os.Stderr, " %s [OPTIONS] -scheduler\n\n", zsdBin)
 		fmt.Fprintf(os.Stderr, "OPTIONS:\n")
 		flag.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\nACTIONS:\n")
@@ -39,6 +46,12 @@ func main() {
 		fmt.Fprintf(os.Stderr, "  cat     <#|SNAPSHOT>: show the file content from the given snapshot\n")
 		fmt.Fprintf(os.Stderr, "  diff    <#|SNAPSHOT>: show a diff from the selected snapshot to the current version\n")
 		fmt.Fprintf(os.Stderr, "  restore <#|SNAPSHOT>: restore the file from the given snapshot\n")
+		fmt.Fprintf(os.Stderr, "  holds   <#|SNAPSHOT>: list the holds from the given snapshot\n")
+		fmt.Fprintf(os.Stderr, "  hold    <#|SNAPSHOT> <TAG>: place a hold with the given tag on the snapshot\n")
+		fmt.Fprintf(os.Stderr, "  release <#|SNAPSHOT> <TAG>: release the hold with the given tag from the snapshot\n")
+		fmt.Fprintf(os.Stderr, "  snapshot [NAME]     : create a snapshot - without a name, the 'snapshot-name-template' is used\n")
+		fmt.Fprintf(os.Stderr, "  retention-plan      : show which snapshots are destroyed per configured retention policy\n")
+		fmt.Fprintf(os.Stderr, "  retention-apply     : destroy the expired snapshots per configured retention policy\n")
 		fmt.Fprintf(os.Stderr, "\nYou can use the snapshot number from the `list` output or the snapshot name to select a snapshot.\n")
 		fmt.Fprintf(os.Stderr, "\nProject home page: https://j-keck.github.io/zfs-snap-diff\n")
 	}
@@ -52,6 +65,11 @@ func main() {
 		return
 	}
 
+	if cliCfg.runScheduler {
+		runScheduler(log)
+		return
+	}
+
 	if len(flag.Args()) < 2 {
 		fmt.Fprintf(os.Stderr, "Argument <FILE> <ACTION> missing (see `%s -h` for help)\n", zsdBin)
 		return
@@ -66,6 +84,12 @@ func main() {
 	}
 	log.Debugf("full path: %s", filePath)
 
+	query, err := zfs.ParseSnapshotQuery(cliCfg.query)
+	if err != nil {
+		log.Errorf("invalid snapshot query - %v", err)
+		return
+	}
+
 	// init zfs handler
 	zfs, ds, err := zfs.NewZFSForFilePath(filePath)
 	if err != nil {
@@ -74,7 +98,18 @@ func main() {
 	}
 	log.Debugf("work on dataset: %s", ds.Name)
 
+	// persist the snapshot inventory between invocations
+	if err := loadSnapshotCache(zfs.SnapshotCache()); err != nil {
+		log.Warnf("unable to load the snapshot cache - %v", err)
+	}
+	defer func() {
+		if err := saveSnapshotCache(zfs.SnapshotCache()); err != nil {
+			log.Warnf("unable to save the snapshot cache - %v", err)
+		}
+	}()
+
 	// action
+	ctx := context.Background()
 	action := flag.Arg(1)
 	switch action {
 	case "list":
@@ -83,8 +118,8 @@ func main() {
 		}
 
 		dr := scanner.NDaysBack(config.Get.DaysToScan, time.Now())
-		sc := scanner.NewScanner(dr, "auto", ds, zfs)
-		scanResult, err := sc.FindFileVersions(filePath)
+		sc := scanner.NewScanner(dr, "auto", ds, zfs, query)
+		scanResult, err := sc.FindFileVersions(ctx, filePath)
 		if err != nil {
 			log.Errorf("scan failed - %v", err)
 			return
@@ -113,8 +148,11 @@ func main() {
 				fmt.Printf("%3d | %-[2]*s | %s\n", idx, width, v.Snapshot.Name, age)
 			}
 		} else {
+			// columns: #, name, created, used, referenced, written, createtxg, guid, holds
 			for idx, v := range scanResult.FileVersions {
-				fmt.Printf("%d\t%s\t%s\n", idx, v.Snapshot.Name, v.Snapshot.Created)
+				s := v.Snapshot
+				fmt.Printf("%d\t%s\t%s\t%d\t%d\t%d\t%d\t%s\t%d\n",
+					idx, s.Name, s.Created, s.Used, s.Referenced, s.Written, s.CreateTXG, s.GUID, s.Holds)
 			}
 		}
 
@@ -175,6 +213,12 @@ func main() {
 			return
 		}
 
+		// the files are read per the transport, but written locally only
+		if tp := transport.Get(); !tp.IsLocal() {
+			log.Errorf("unable to restore the file per the transport: '%s' - only reads are supported", tp)
+			return
+		}
+
 		versionName := flag.Arg(2)
 		version, err := lookupRequestedVersion(filePath, versionName)
 		if err != nil {
@@ -192,18 +236,185 @@ func main() {
 		}
 
 		// restore the backup version
-		version.Backup.Copy(version.Current.Path)
+		if err := version.Backup.Copy(version.Current.Path); err != nil {
+			log.Errorf("unable to restore the version from snapshot: %s - %v", version.Snapshot.Name, err)
+			return
+		}
 
 		if !cliCfg.scriptingOutput {
 			fmt.Printf("version restored from snapshot: %s\n", version.Snapshot.Name)
 		}
 
+	case "holds":
+		if len(flag.Args()) != 3 {
+			fmt.Fprintf(os.Stderr, "Argument <#|SNAPSHOT> missing (see `%s -h` for help)\n", zsdBin)
+			return
+		}
+
+		versionName := flag.Arg(2)
+		version, err := lookupRequestedVersion(filePath, versionName)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		holds, err := ds.ListHolds(ctx, version.Snapshot.Name)
+		if err != nil {
+			log.Errorf("unable to list holds - %v", err)
+			return
+		}
+
+		if !cliCfg.scriptingOutput {
+			fmt.Printf("%d holds on snapshot: %s\n", len(holds), version.Snapshot.Name)
+			for _, h := range holds {
+				fmt.Printf("  %s (since %s)\n", h.Tag, h.Created.Format("Jan 2 15:04"))
+			}
+		} else {
+			for _, h := range holds {
+				fmt.Printf("%s\t%s\n", h.Tag, h.Created)
+			}
+		}
+
+	case "hold", "release":
+		if len(flag.Args()) != 4 {
+			fmt.Fprintf(os.Stderr, "Argument <#|SNAPSHOT> <TAG> missing (see `%s -h` for help)\n", zsdBin)
+			return
+		}
+
+		versionName := flag.Arg(2)
+		version, err := lookupRequestedVersion(filePath, versionName)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+
+		tag := flag.Arg(3)
+		if action == "hold" {
+			err = ds.HoldSnapshot(ctx, version.Snapshot.Name, tag, nil)
+		} else {
+			err = ds.ReleaseSnapshot(ctx, version.Snapshot.Name, tag, nil)
+		}
+		if err != nil {
+			log.Errorf("unable to %s snapshot: %s - %v", action, version.Snapshot.Name, err)
+			return
+		}
+
+		if !cliCfg.scriptingOutput {
+			if action == "hold" {
+				fmt.Printf("hold '%s' placed on snapshot: %s\n", tag, version.Snapshot.Name)
+			} else {
+				fmt.Printf("hold '%s' released from snapshot: %s\n", tag, version.Snapshot.Name)
+			}
+		}
+
+	case "snapshot":
+		name := flag.Arg(2)
+		if len(name) == 0 {
+			name, err = ds.SnapshotNameFromTemplate(config.Get.SnapshotNameTemplate, time.Now())
+			if err != nil {
+				log.Errorf("unable to create the snapshot name from template: '%s' - %v",
+					config.Get.SnapshotNameTemplate, err)
+				return
+			}
+		}
+
+		fullName, err := ds.CreateSnapshot(ctx, name, nil, nil)
+		if err != nil {
+			log.Errorf("unable to create snapshot: %s - %v", name, err)
+			return
+		}
+
+		if !cliCfg.scriptingOutput {
+			fmt.Printf("snapshot created: %s\n", fullName)
+		} else {
+			fmt.Println(fullName)
+		}
+
+	case "retention-plan", "retention-apply":
+		policies := retention.PoliciesForDataset(ds.Name)
+		if len(policies) == 0 {
+			log.Errorf("no retention policy for dataset: %s configured", ds.Name)
+			return
+		}
+
+		for _, policy := range policies {
+			plan, err := retention.PlanForDataset(ctx, ds, policy)
+			if err != nil {
+				log.Errorf("unable to compute the retention plan - %v", err)
+				return
+			}
+
+			if action == "retention-apply" {
+				destroyed, err := plan.Apply(ctx, ds)
+				if !cliCfg.scriptingOutput {
+					fmt.Printf("%d snapshots destroyed\n", len(destroyed))
+				} else {
+					for _, name := range destroyed {
+						fmt.Println(name)
+					}
+				}
+				if err != nil {
+					log.Error(err)
+					return
+				}
+				continue
+			}
+
+			if !cliCfg.scriptingOutput {
+				fmt.Printf("retention plan for dataset: %s, snapshots: '%s'\n", ds.Name, policy.NamePattern)
+				for _, d := range plan.Keep {
+					fmt.Printf("  keep    %s (%s)\n", d.Snapshot.Name, strings.Join(d.Reasons, ", "))
+				}
+				for _, d := range plan.Destroy {
+					fmt.Printf("  destroy %s\n", d.Snapshot.Name)
+				}
+			} else {
+				for _, d := range plan.Keep {
+					fmt.Printf("keep\t%s\t%s\n", d.Snapshot.Name, strings.Join(d.Reasons, ","))
+				}
+				for _, d := range plan.Destroy {
+					fmt.Printf("destroy\t%s\t%s\n", d.Snapshot.Name, strings.Join(d.Reasons, ","))
+				}
+			}
+		}
+
 	default:
 		fmt.Fprintf(os.Stderr, "invalid action: %s (see `%s -h` for help)\n", action, zsdBin)
 		return
 	}
 }
 
+// runScheduler creates the configured snapshot schedules in the foreground
+func runScheduler(log plog.Logger) {
+	var datasetNames []string
+	for _, schedule := range config.Get.SnapshotSchedules {
+		datasetNames = append(datasetNames, schedule.DatasetName)
+	}
+
+	if len(datasetNames) == 0 {
+		log.Error("no snapshot schedules configured (see 'snapshot-schedule' in the config file)")
+		return
+	}
+
+	z, err := zfs.NewZFS(datasetNames...)
+	if err != nil {
+		log.Errorf("unable to get zfs handler for datasets: '%s' - %v", strings.Join(datasetNames, ", "), err)
+		return
+	}
+
+	sched, err := scheduler.NewSchedulerFromConfig(z)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	if len(sched.Jobs()) == 0 {
+		log.Error("no snapshot schedules for the configured datasets")
+		return
+	}
+	sched.Run(context.Background())
+}
+
 func lookupRequestedVersion(filePath, versionName string) (*scanner.FileVersion, error) {
 
 	// load file-versions from cache file
@@ -270,6 +481,10 @@ func parseFlags() CliConfig {
 		"Scripting mode. Do not print headers, print absolute dates and separate fields by a single tab")
 	flag.BoolVar(&cliCfg.snapshotTimemachineOutput, "snapshot-timemachine", false,
 		"Special output for Snapshot-timemachine (https://github.com/mrBliss/snapshot-timemachine)")
+	flag.BoolVar(&cliCfg.runScheduler, "scheduler", false,
+		"run the configured snapshot schedules in the foreground")
+	flag.StringVar(&cliCfg.query, "q", "",
+		"only scan the snapshots which match the query - like 'name=daily-* created>=2020-05-01 used>1M holds>0'")
 
 	// logging
 	cliCfg.logLevel = plog.Note
@@ -278,9 +493,18 @@ func parseFlags() CliConfig {
 
 	// zfs
 	zfsCfg := &config.Get.ZFS
-	flag.BoolVar(&zfsCfg.UseSudo, "use-sudo", zfsCfg.UseSudo, "use sudo when executing 'zfs' commands")
+	flag.BoolVar(&zfsCfg.UseSudo, "use-sudo", zfsCfg.UseSudo,
+		"use sudo when executing 'zfs' commands - shortcut for '-escalation-command sudo' for all zfs commands")
+	flag.StringVar(&zfsCfg.EscalationCommand, "escalation-command", zfsCfg.EscalationCommand,
+		"command prefix to elevate zfs commands per the configured 'escalate' policy - mutating commands per default (like 'doas' or 'sudo -n -u zfsadmin')")
+	flag.Var(&config.Get.Transport.Wrapper, "transport",
+		"wrapper command to execute zfs commands and snapshot file reads (like 'ssh host' or 'nsenter -t PID -m')")
+	flag.BoolVar(&config.Get.Transport.Shell, "transport-shell", config.Get.Transport.Shell,
+		"the transport wrapper passes the command through a shell - quote the arguments ('ssh' and 'machinectl shell' are always quoted)")
 	flag.BoolVar(&zfsCfg.MountSnapshots, "mount-snapshots", zfsCfg.MountSnapshots,
 		"mount snapshot (only necessary if it's not mounted by zfs automatically)")
+	flag.DurationVar(&zfsCfg.SnapshotCacheTTL.Duration, "snapshot-cache-ttl", zfsCfg.SnapshotCacheTTL.Duration,
+		"max. age of cached snapshot lists - '0s' disables the cache")
 
 	flag.Parse()
 	return *cliCfg
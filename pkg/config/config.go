@@ -12,6 +12,7 @@ var log = plog.GlobalLogger()
 var Get Config = Config{
 	Webserver:                NewDefaultWebserverConfig(),
 	ZFS:                      NewDefaultZFSConfig(),
+	Transport:                NewDefaultTransportConfig(),
 	UseCacheDirForBackups:    true,
 	DaysToScan:               2,
 	MaxArchiveUnpackedSizeMB: 200,
@@ -21,14 +22,17 @@ var Get Config = Config{
 }
 
 type Config struct {
-	Webserver                WebserverConfig `toml:"webserver"`
-	ZFS                      ZFSConfig       `toml:"zfs"`
-	UseCacheDirForBackups    bool            `toml:"use-cache-dir-for-backups"`
-	DaysToScan               int             `toml:"days-to-scan"`
-	MaxArchiveUnpackedSizeMB int             `toml:"max-archive-unpacked-size-mb"`
-	SnapshotNameTemplate     string          `toml:"snapshot-name-template"`
-	CompareMethod            string          `toml:"compare-method"`
-	DiffContextSize          int             `toml:"diff-context-size"`
+	Webserver                WebserverConfig    `toml:"webserver"`
+	ZFS                      ZFSConfig          `toml:"zfs"`
+	Transport                TransportConfig    `toml:"transport"`
+	UseCacheDirForBackups    bool               `toml:"use-cache-dir-for-backups"`
+	DaysToScan               int                `toml:"days-to-scan"`
+	MaxArchiveUnpackedSizeMB int                `toml:"max-archive-unpacked-size-mb"`
+	SnapshotNameTemplate     string             `toml:"snapshot-name-template"`
+	CompareMethod            string             `toml:"compare-method"`
+	DiffContextSize          int                `toml:"diff-context-size"`
+	Retention                []RetentionPolicy  `toml:"retention,omitempty"`
+	SnapshotSchedules        []SnapshotSchedule `toml:"snapshot-schedule,omitempty"`
 }
 
 func LoadConfig(path string) {
@@ -0,0 +1,16 @@
+package config
+
+// RetentionPolicy describes which snapshots from a dataset are kept.
+//
+// For each rule, the newest snapshot per period (hour, day, ...)
+// is kept for the given number of periods.
+type RetentionPolicy struct {
+	DatasetName string `toml:"dataset-name" json:"datasetName"`
+	// NamePattern restricts the policy to snapshots with a matching name (glob syntax)
+	NamePattern string `toml:"name-pattern" json:"namePattern"`
+	Hourly      int    `toml:"hourly" json:"hourly"`
+	Daily       int    `toml:"daily" json:"daily"`
+	Weekly      int    `toml:"weekly" json:"weekly"`
+	Monthly     int    `toml:"monthly" json:"monthly"`
+	Yearly      int    `toml:"yearly" json:"yearly"`
+}
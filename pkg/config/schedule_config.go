@@ -0,0 +1,29 @@
+package config
+
+import (
+	"time"
+)
+
+// SnapshotSchedule describes a periodic snapshot for a dataset
+type SnapshotSchedule struct {
+	DatasetName string   `toml:"dataset-name" json:"datasetName"`
+	Interval    Duration `toml:"interval" json:"interval"`
+	Recursive   bool     `toml:"recursive" json:"recursive"`
+	// NameTemplate overrides the global 'snapshot-name-template' when given
+	NameTemplate string `toml:"name-template,omitempty" json:"nameTemplate"`
+}
+
+// Duration is a time.Duration which is en- / decoded as text (like "1h30m")
+type Duration struct {
+	time.Duration
+}
+
+func (self *Duration) UnmarshalText(text []byte) error {
+	d, err := time.ParseDuration(string(text))
+	self.Duration = d
+	return err
+}
+
+func (self Duration) MarshalText() ([]byte, error) {
+	return []byte(self.String()), nil
+}
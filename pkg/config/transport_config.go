@@ -0,0 +1,42 @@
+package config
+
+import (
+	"strings"
+)
+
+// TransportConfig configures how zfs commands and file reads are executed.
+//
+// Without a wrapper, everything runs local. With a wrapper, the commands
+// are executed per the wrapper command - like 'ssh host', 'nsenter -t PID -m'
+// or 'machinectl shell container'.
+type TransportConfig struct {
+	// Wrapper is the command (argv) which executes the wrapped command
+	Wrapper Argv `toml:"wrapper"`
+	// Shell indicates that the wrapper passes the command through a shell.
+	// in this case, the arguments from the wrapped command are quoted.
+	// 'ssh' and 'machinectl shell' wrappers are always quoted.
+	Shell bool `toml:"shell"`
+}
+
+// IsLocal checks if no wrapper is configured
+func (self *TransportConfig) IsLocal() bool {
+	return len(self.Wrapper) == 0
+}
+
+// Argv is a command with arguments.
+//
+// It can be used as a flag - the flag value gets split on white spaces.
+type Argv []string
+
+func (self *Argv) String() string {
+	return strings.Join(*self, " ")
+}
+
+func (self *Argv) Set(s string) error {
+	*self = strings.Fields(s)
+	return nil
+}
+
+func NewDefaultTransportConfig() TransportConfig {
+	return TransportConfig{}
+}
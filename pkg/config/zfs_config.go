@@ -2,11 +2,67 @@ package config
 
 import (
 	"runtime"
+	"strings"
+	"time"
 )
 
+// MutatingSubCommands are the zfs sub-commands which modify datasets or snapshots
+var MutatingSubCommands = []string{
+	"bookmark", "clone", "create", "destroy", "hold", "inherit", "load-key",
+	"mount", "promote", "receive", "release", "rename", "rollback", "set", "snapshot",
+}
+
 type ZFSConfig struct {
-	UseSudo        bool `toml:"use-sudo"`
-	MountSnapshots bool `toml:"mount-snapshots"`
+	// UseSudo is a shortcut for: escalation-command = "sudo" and escalate = ["all"]
+	UseSudo bool `toml:"use-sudo"`
+	// EscalationCommand is the command prefix to elevate zfs commands -
+	// like "sudo", "doas", "pfexec" or "sudo -n -u zfsadmin"
+	EscalationCommand string `toml:"escalation-command"`
+	// Escalate are the zfs sub-commands which are executed per 'EscalationCommand'.
+	// "mutating" stands for all 'MutatingSubCommands', "all" for every sub-command.
+	Escalate       []string `toml:"escalate"`
+	MountSnapshots bool     `toml:"mount-snapshots"`
+	// SnapshotCacheTTL is the max. age from cached snapshot lists - "0s" disables the cache
+	SnapshotCacheTTL Duration `toml:"snapshot-cache-ttl"`
+	// Timeouts are the max. runtimes per zfs sub-command (like "list" or "destroy").
+	// Sub-commands without an entry use the "default" entry - "0s" disables the timeout
+	Timeouts map[string]Duration `toml:"timeouts"`
+}
+
+// EscalationFor returns the command prefix for the given zfs sub-command - nil if not elevated
+func (self *ZFSConfig) EscalationFor(subCmd string) []string {
+	if self.UseSudo {
+		return []string{"sudo"}
+	}
+
+	prefix := strings.Fields(self.EscalationCommand)
+	if len(prefix) == 0 {
+		return nil
+	}
+
+	for _, e := range self.Escalate {
+		if e == "all" || e == subCmd || (e == "mutating" && isMutating(subCmd)) {
+			return prefix
+		}
+	}
+	return nil
+}
+
+func isMutating(subCmd string) bool {
+	for _, c := range MutatingSubCommands {
+		if c == subCmd {
+			return true
+		}
+	}
+	return false
+}
+
+// TimeoutFor returns the timeout for the given zfs sub-command - zero if disabled
+func (self *ZFSConfig) TimeoutFor(subCmd string) time.Duration {
+	if d, ok := self.Timeouts[subCmd]; ok {
+		return d.Duration
+	}
+	return self.Timeouts["default"].Duration
 }
 
 func NewDefaultZFSConfig() ZFSConfig {
@@ -16,7 +72,16 @@ func NewDefaultZFSConfig() ZFSConfig {
 	}
 
 	return ZFSConfig{
-		UseSudo:        false,
-		MountSnapshots: mountSnapshots,
+		UseSudo:          false,
+		Escalate:         []string{"mutating"},
+		MountSnapshots:   mountSnapshots,
+		SnapshotCacheTTL: Duration{time.Minute},
+		Timeouts: map[string]Duration{
+			"default": {2 * time.Minute},
+			"diff":    {10 * time.Minute},
+			// streams can run for hours
+			"send":    {0},
+			"receive": {0},
+		},
 	}
 }
@@ -87,7 +87,7 @@ func (self *DirHandle) WriteFile(name string, data []byte, perm os.FileMode) (Fi
 // Directory and filenames are grouped and sorted by names.
 func (self *DirHandle) Ls() ([]FSHandle, error) {
 	log.Tracef("list directory content under: %s", self.Path)
-	ls, err := ioutil.ReadDir(self.Path)
+	ls, err := readDir(self.Path)
 	if err != nil {
 		return nil, err
 	}
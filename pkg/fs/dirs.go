@@ -9,6 +9,14 @@ import (
 
 // ConfigDir returns the user-local config directory
 func ConfigDir() (DirHandle, error) {
+	path, err := configDirPath()
+	if err != nil {
+		return DirHandle{}, err
+	}
+	return GetOrCreateDirHandle(path, 0770)
+}
+
+func configDirPath() (string, error) {
 	// lookup base path - somethink like '$HOME/.config'
 
 	// `os.UserConfigDir()` exists since go1.13, but the
@@ -17,7 +25,7 @@ func ConfigDir() (DirHandle, error) {
 	if runtime.GOOS == "darwin" {
 		basePath = os.Getenv("HOME")
 		if basePath == "" {
-			return DirHandle{}, errors.New("$HOME is not defined")
+			return "", errors.New("$HOME is not defined")
 		}
 		basePath += "/Library/Application Support"
 	} else {
@@ -26,27 +34,32 @@ func ConfigDir() (DirHandle, error) {
 		if basePath == "" {
 			basePath = os.Getenv("HOME")
 			if basePath == "" {
-				return DirHandle{}, errors.New("neither $XDG_CONFIG_HOME nor $HOME are defined")
+				return "", errors.New("neither $XDG_CONFIG_HOME nor $HOME are defined")
 			}
 			basePath += "/.config"
 		}
 	}
 
-	path := filepath.Join(basePath, "zfs-snap-diff")
-	return GetOrCreateDirHandle(path, 0770)
+	return filepath.Join(basePath, "zfs-snap-diff"), nil
 }
 
 // CacheDir returns the user-local cache directory
 func CacheDir() (DirHandle, error) {
+	path, err := cacheDirPath()
+	if err != nil {
+		return DirHandle{}, err
+	}
+	return GetOrCreateDirHandle(path, 0770)
+}
+
+func cacheDirPath() (string, error) {
 	// lookup base path - somethink like '$HOME/.cache'
 	// since go1.11: https://golang.org/pkg/os/#UserCacheDir
 	basePath, err := os.UserCacheDir()
 	if err != nil {
-		return DirHandle{}, err
+		return "", err
 	}
-
-	path := filepath.Join(basePath, "zfs-snap-diff")
-	return GetOrCreateDirHandle(path, 0770)
+	return filepath.Join(basePath, "zfs-snap-diff"), nil
 }
 
 // TempDir returns the directory for temporary files
@@ -31,7 +31,7 @@ func GetFileHandle(path string) (FileHandle, error) {
 // MimeType returns file mime-type.
 // This functions makes io-operations to read a part of the file.
 func (self *FileHandle) MimeType() (string, error) {
-	fh, err := os.Open(self.Path)
+	fh, err := Open(self.Path)
 	if err != nil {
 		return "", err
 	}
@@ -40,8 +40,8 @@ func (self *FileHandle) MimeType() (string, error) {
 	// read the first 512 bytes
 	//  * http.DetectContentType considers at most 512 bytes
 	buf := make([]byte, 512)
-	n, err := fh.Read(buf)
-	if err != nil {
+	n, err := io.ReadFull(fh, buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
 		return "", err
 	}
 
@@ -50,7 +50,13 @@ func (self *FileHandle) MimeType() (string, error) {
 
 // Read returns the whole file content as a byte array.
 func (self *FileHandle) Read() ([]byte, error) {
-	buf, err := ioutil.ReadFile(self.Path)
+	fh, err := Open(self.Path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file content: %v", err)
+	}
+	defer fh.Close()
+
+	buf, err := ioutil.ReadAll(fh)
 	if err != nil && err != io.EOF {
 		return nil, fmt.Errorf("unable to read file content: %v", err)
 	}
@@ -65,7 +71,7 @@ func (self *FileHandle) ReadString() (string, error) {
 
 // CopyTo copies the whole file content into a given writer.
 func (self *FileHandle) CopyTo(w io.Writer) error {
-	fh, err := os.Open(self.Path)
+	fh, err := Open(self.Path)
 	if err != nil {
 		return err
 	}
@@ -75,12 +81,18 @@ func (self *FileHandle) CopyTo(w io.Writer) error {
 	return err
 }
 
+// Open opens the file for reading.
+func (self *FileHandle) Open() (io.ReadCloser, error) {
+	return Open(self.Path)
+}
+
 // Copy copies a file.
 func (fh *FileHandle) Copy(path string) (err error) {
-	var src, dst *os.File
+	var src io.ReadCloser
+	var dst *os.File
 
 	// open src
-	if src, err = os.Open(fh.Path); err != nil {
+	if src, err = Open(fh.Path); err != nil {
 		return err
 	}
 	defer src.Close()
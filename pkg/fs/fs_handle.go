@@ -27,7 +27,7 @@ func GetFSHandle(path string) (FSHandle, error) {
 		return FSHandle{}, errors.New("the given path was empty")
 	}
 
-	fileInfo, err := os.Stat(path)
+	fileInfo, err := stat(path)
 	if err != nil {
 		return FSHandle{}, err
 	}
@@ -0,0 +1,300 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/j-keck/zfs-snap-diff/pkg/transport"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// the read operations from this package (stat, directory listings, file reads)
+// are executed per the configured transport - see 'transport.Get'.
+//
+// the directories from zfs-snap-diff (config, cache and temp directory) are always local.
+// write operations work only on the local filesystem.
+
+// isLocal checks if the given path is accessed per the local filesystem
+func isLocal(tp transport.Transport, path string) bool {
+	if tp.IsLocal() {
+		return true
+	}
+
+	for _, dir := range localDirs() {
+		if dir != "" && (path == dir || strings.HasPrefix(path, dir+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+func localDirs() []string {
+	configDir, _ := configDirPath()
+	cacheDir, _ := cacheDirPath()
+	return []string{configDir, cacheDir, filepath.Clean(os.TempDir())}
+}
+
+// Open opens the file for reading - per the configured transport
+func Open(path string) (io.ReadCloser, error) {
+	tp := transport.Get()
+	if isLocal(tp, path) {
+		return os.Open(path)
+	}
+
+	log.Tracef("open file: %s per transport: %s", path, tp)
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := tp.Command(ctx, "cat", "--", path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, err
+	}
+	return &remoteFile{stdout, cmd, cancel, &stderr, path}, nil
+}
+
+// remoteFile is the stdout from the 'cat' command
+type remoteFile struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	cancel context.CancelFunc
+	stderr *bytes.Buffer
+	path   string
+}
+
+func (self *remoteFile) Read(p []byte) (int, error) {
+	n, err := self.ReadCloser.Read(p)
+	if err == io.EOF {
+		// report a failed 'cat' instead of an empty file
+		if waitErr := self.wait(); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+func (self *remoteFile) Close() error {
+	self.cancel()
+	self.wait()
+	return nil
+}
+
+func (self *remoteFile) wait() error {
+	if self.cmd.ProcessState != nil {
+		return nil
+	}
+	if err := self.cmd.Wait(); err != nil {
+		return remoteError("open", self.path, self.stderr.String(), err)
+	}
+	return nil
+}
+
+// stat returns the file info - symlinks are followed
+func stat(path string) (os.FileInfo, error) {
+	tp := transport.Get()
+	if isLocal(tp, path) {
+		return os.Stat(path)
+	}
+
+	flavor, err := statFlavorFor(tp)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := runRemote(tp, "stat", path, "stat", append([]string{"-L"}, append(flavor.args(), "--", path)...)...)
+	if err != nil {
+		return nil, err
+	}
+	return parseStatLine(strings.TrimRight(out, "\n"))
+}
+
+// readDir returns the file infos from the directory content - symlinks are not followed
+func readDir(path string) ([]os.FileInfo, error) {
+	tp := transport.Get()
+	if isLocal(tp, path) {
+		return ioutil.ReadDir(path)
+	}
+
+	flavor, err := statFlavorFor(tp)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"--", path, "-mindepth", "1", "-maxdepth", "1", "-exec", "stat"}
+	args = append(append(args, flavor.args()...), "{}", "+")
+	out, err := runRemote(tp, "readdir", path, "find", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := []os.FileInfo{}
+	for _, line := range strings.Split(out, "\n") {
+		if len(line) == 0 {
+			continue
+		}
+		info, err := parseStatLine(line)
+		if err != nil {
+			log.Debugf("ignore invalid formatted line: '%s' - %v", line, err)
+			continue
+		}
+		infos = append(infos, info)
+	}
+
+	// same order as 'ioutil.ReadDir'
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+func runRemote(tp transport.Transport, op, path string, name string, args ...string) (string, error) {
+	cmd := tp.Command(context.Background(), name, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", remoteError(op, path, stderr.String(), err)
+	}
+	return stdout.String(), nil
+}
+
+// remoteError maps the error from a command to a 'os.PathError'
+func remoteError(op, path, stderr string, err error) error {
+	stderr = strings.TrimSpace(stderr)
+	switch {
+	case strings.Contains(stderr, "No such file or directory"):
+		err = syscall.ENOENT
+	case strings.Contains(stderr, "Permission denied"):
+		err = syscall.EACCES
+	case strings.Contains(stderr, "Not a directory"):
+		err = syscall.ENOTDIR
+	case len(stderr) > 0:
+		err = errors.New(stderr)
+	}
+	return &os.PathError{Op: op, Path: path, Err: err}
+}
+
+// statFlavor is the flavor from the 'stat' command - the format arguments differ
+type statFlavor int
+
+const (
+	gnuStat statFlavor = iota
+	bsdStat
+)
+
+// args returns the arguments for the output format: '<mode>|<size>|<mtime>|<path>'.
+// the mode is in hex (gnu) or octal (bsd) - with the file type bits from 'stat(2)'
+func (self statFlavor) args() []string {
+	if self == bsdStat {
+		return []string{"-f", "o%p|%z|%m|%N"}
+	}
+	return []string{"-c", "x%f|%s|%Y|%n"}
+}
+
+var statFlavors = struct {
+	sync.Mutex
+	byTransport map[string]statFlavor
+}{byTransport: make(map[string]statFlavor)}
+
+// statFlavorFor detects the 'stat' flavor per transport - only gnu stat supports '--version'
+func statFlavorFor(tp transport.Transport) (statFlavor, error) {
+	statFlavors.Lock()
+	defer statFlavors.Unlock()
+
+	if flavor, ok := statFlavors.byTransport[tp.String()]; ok {
+		return flavor, nil
+	}
+
+	flavor := bsdStat
+	if out, err := tp.Command(context.Background(), "stat", "--version").Output(); err == nil {
+		if !strings.Contains(string(out), "GNU") {
+			return flavor, fmt.Errorf("unsupported 'stat' command per transport: %s", tp)
+		}
+		flavor = gnuStat
+	}
+	log.Debugf("detected stat flavor: %v for transport: %s", flavor, tp)
+	statFlavors.byTransport[tp.String()] = flavor
+	return flavor, nil
+}
+
+// parseStatLine parses a line from the 'stat' output in the 'statFlavor.args' format
+func parseStatLine(line string) (os.FileInfo, error) {
+	fields := strings.SplitN(line, "|", 4)
+	if len(fields) != 4 || len(fields[0]) < 2 {
+		return nil, fmt.Errorf("unexpected stat output: '%s'", line)
+	}
+
+	base := 16
+	if fields[0][0] == 'o' {
+		base = 8
+	}
+	rawMode, err := strconv.ParseUint(fields[0][1:], base, 32)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse mode: '%s' - %v", fields[0], err)
+	}
+
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse size: '%s' - %v", fields[1], err)
+	}
+
+	mtime, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse mtime: '%s' - %v", fields[2], err)
+	}
+
+	return &remoteFileInfo{
+		name:  filepath.Base(fields[3]),
+		size:  size,
+		mode:  fileModeFromStat(uint32(rawMode)),
+		mtime: time.Unix(mtime, 0),
+	}, nil
+}
+
+// fileModeFromStat converts the 'st_mode' from 'stat(2)'
+func fileModeFromStat(m uint32) os.FileMode {
+	mode := os.FileMode(m & 0777)
+	switch m & syscall.S_IFMT {
+	case syscall.S_IFDIR:
+		mode |= os.ModeDir
+	case syscall.S_IFLNK:
+		mode |= os.ModeSymlink
+	case syscall.S_IFIFO:
+		mode |= os.ModeNamedPipe
+	case syscall.S_IFSOCK:
+		mode |= os.ModeSocket
+	case syscall.S_IFBLK:
+		mode |= os.ModeDevice
+	case syscall.S_IFCHR:
+		mode |= os.ModeDevice | os.ModeCharDevice
+	}
+	return mode
+}
+
+type remoteFileInfo struct {
+	name  string
+	size  int64
+	mode  os.FileMode
+	mtime time.Time
+}
+
+func (self *remoteFileInfo) Name() string       { return self.name }
+func (self *remoteFileInfo) Size() int64        { return self.size }
+func (self *remoteFileInfo) Mode() os.FileMode  { return self.mode }
+func (self *remoteFileInfo) ModTime() time.Time { return self.mtime }
+func (self *remoteFileInfo) IsDir() bool        { return self.mode.IsDir() }
+func (self *remoteFileInfo) Sys() interface{}   { return nil }
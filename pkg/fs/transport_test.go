@@ -0,0 +1,105 @@
+package fs
+
+import (
+	"github.com/j-keck/zfs-snap-diff/pkg/config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTransportReads(t *testing.T) {
+	defer func(cfg config.TransportConfig) { config.Get.Transport = cfg }(config.Get.Transport)
+
+	// the temp directory is always accessed local - use the working directory
+	dir, err := ioutil.TempDir(".", "transport-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dir, _ = filepath.Abs(dir)
+
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "file with spaces.txt"), []byte("content"), 0644)
+
+	// the wrapper logs the wrapped commands
+	logPath := filepath.Join(dir, "sub", "wrapper.log")
+	config.Get.Transport = config.TransportConfig{
+		Wrapper: config.Argv{"sh", "-c", `echo "$1" >> "$0"; exec "$@"`, logPath},
+	}
+
+	fh, err := GetFileHandle(filepath.Join(dir, "file with spaces.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fh.Size != 7 {
+		t.Errorf("unexpected size: %d", fh.Size)
+	}
+
+	content, err := fh.ReadString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "content" {
+		t.Errorf("unexpected content: '%s'", content)
+	}
+
+	dh, err := GetDirHandle(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ls, err := dh.Ls()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ls) != 2 || ls[0].Name != "sub" || ls[0].Kind != DIR || ls[1].Kind != FILE {
+		t.Errorf("unexpected listing: %v", ls)
+	}
+
+	if _, err := GetFSHandle(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("unexpected error for a missing file: %v", err)
+	}
+
+	if _, err := GetFileHandle(filepath.Join(dir, "missing")); err == nil {
+		t.Error("file handle for a missing file")
+	}
+
+	// a failed read is reported
+	if _, err := (&FileHandle{FSHandle{Path: filepath.Join(dir, "missing")}}).Read(); err == nil {
+		t.Error("read from a missing file")
+	}
+
+	wrapperLog, err := ioutil.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cmd := range []string{"stat", "find", "cat"} {
+		if !strings.Contains(string(wrapperLog), cmd) {
+			t.Errorf("'%s' not executed per the wrapper - log: %s", cmd, wrapperLog)
+		}
+	}
+}
+
+func TestParseStatLine(t *testing.T) {
+	for line, expected := range map[string]Kind{
+		"x81a4|7|1600000000|/a/file":     FILE,
+		"x41ed|4096|1600000000|/a/dir":   DIR,
+		"xa1ff|4|1600000000|/a/link":     LINK,
+		"o100644|7|1600000000|/a/file":   FILE,
+		"o40755|512|1600000000|/a/b|c/d": DIR,
+		"o20666|0|1600000000|/dev/null":  DEV,
+	} {
+		info, err := parseStatLine(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kind := KindFromFileInfo(info); kind != expected {
+			t.Errorf("unexpected kind for '%s': %s - expected: %s", line, kind, expected)
+		}
+	}
+
+	if _, err := parseStatLine("invalid"); err == nil {
+		t.Error("invalid line parsed")
+	}
+}
@@ -0,0 +1,158 @@
+// Package retention computes which snapshots are kept per retention policy.
+//
+// A policy like "keep 24 hourly, 14 daily, 8 weekly, 12 monthly" keeps
+// for each rule the newest snapshot per period (hour, day, week, ...)
+// for the given number of periods. All other snapshots matching the
+// policy name pattern are destroyed.
+//
+// Snapshots with holds or clones are never destroyed.
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/j-keck/plog"
+	"github.com/j-keck/zfs-snap-diff/pkg/config"
+	"github.com/j-keck/zfs-snap-diff/pkg/zfs"
+	"path"
+	"strings"
+	"time"
+)
+
+var log = plog.GlobalLogger()
+
+// Decision describes why a snapshot is kept or destroyed
+type Decision struct {
+	Snapshot zfs.Snapshot `json:"snapshot"`
+	Reasons  []string     `json:"reasons"`
+}
+
+// Plan contains the snapshots which are kept and which would be destroyed
+type Plan struct {
+	Policy  config.RetentionPolicy `json:"policy"`
+	Keep    []Decision             `json:"keep"`
+	Destroy []Decision             `json:"destroy"`
+}
+
+// PoliciesForDataset returns the configured policies for the given dataset
+func PoliciesForDataset(name string) []config.RetentionPolicy {
+	var policies []config.RetentionPolicy
+	for _, p := range config.Get.Retention {
+		if p.DatasetName == name {
+			policies = append(policies, p)
+		}
+	}
+	return policies
+}
+
+// Validate checks if the policy is usable.
+// A policy without any rule would destroy all matching snapshots.
+func Validate(policy config.RetentionPolicy) error {
+	if policy.Hourly < 0 || policy.Daily < 0 || policy.Weekly < 0 ||
+		policy.Monthly < 0 || policy.Yearly < 0 {
+		return errors.New("negative values are not allowed in a retention policy")
+	}
+
+	if policy.Hourly+policy.Daily+policy.Weekly+policy.Monthly+policy.Yearly == 0 {
+		return errors.New("retention policy without any rule")
+	}
+
+	if _, err := path.Match(policy.NamePattern, ""); err != nil {
+		return fmt.Errorf("invalid name-pattern: '%s' - %v", policy.NamePattern, err)
+	}
+	return nil
+}
+
+// PlanForDataset scans the snapshots from the given dataset and computes the plan
+func PlanForDataset(ctx context.Context, ds zfs.Dataset, policy config.RetentionPolicy) (Plan, error) {
+	if err := Validate(policy); err != nil {
+		return Plan{}, err
+	}
+
+	snaps, err := ds.ScanSnapshots(ctx)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	return Compute(policy, snaps), nil
+}
+
+// Compute computes the plan for the given snapshots.
+//
+// The snapshots must be sorted by the creation date - newest first
+// (as returned from 'Dataset.ScanSnapshots').
+func Compute(policy config.RetentionPolicy, snaps zfs.Snapshots) Plan {
+	// only snapshots with a matching name are managed by the policy
+	snaps = snaps.Filter(func(s zfs.Snapshot) bool {
+		if policy.NamePattern == "" {
+			return true
+		}
+		matched, _ := path.Match(policy.NamePattern, s.Name)
+		return matched
+	})
+
+	reasons := make([][]string, len(snaps))
+	keep := func(name string, n int, period func(time.Time) string) {
+		seen := make(map[string]bool)
+		for idx, snap := range snaps {
+			if len(seen) >= n {
+				break
+			}
+
+			p := period(snap.Created)
+			if !seen[p] {
+				seen[p] = true
+				reasons[idx] = append(reasons[idx], name)
+			}
+		}
+	}
+
+	keep("hourly", policy.Hourly, func(t time.Time) string { return t.Format("2006-01-02T15") })
+	keep("daily", policy.Daily, func(t time.Time) string { return t.Format("2006-01-02") })
+	keep("weekly", policy.Weekly, func(t time.Time) string {
+		y, w := t.ISOWeek()
+		return fmt.Sprintf("%d-%d", y, w)
+	})
+	keep("monthly", policy.Monthly, func(t time.Time) string { return t.Format("2006-01") })
+	keep("yearly", policy.Yearly, func(t time.Time) string { return t.Format("2006") })
+
+	plan := Plan{Policy: policy, Keep: []Decision{}, Destroy: []Decision{}}
+	for idx, snap := range snaps {
+		if snap.Holds > 0 {
+			reasons[idx] = append(reasons[idx], "held")
+		}
+
+		if len(snap.Clones) > 0 {
+			reasons[idx] = append(reasons[idx], "has clones")
+		}
+
+		if len(reasons[idx]) > 0 {
+			plan.Keep = append(plan.Keep, Decision{snap, reasons[idx]})
+		} else {
+			plan.Destroy = append(plan.Destroy, Decision{snap, []string{"expired"}})
+		}
+	}
+	return plan
+}
+
+// Apply destroys the snapshots from the plan.
+//
+// It tries to destroy all snapshots - failed snapshots are reported in the returned error.
+func (self *Plan) Apply(ctx context.Context, ds zfs.Dataset) ([]string, error) {
+	var destroyed, failed []string
+	for _, d := range self.Destroy {
+		log.Debugf("retention - destroy snapshot: %s", d.Snapshot.FullName)
+		if err := ds.DestroySnapshot(ctx, d.Snapshot.Name, nil); err != nil {
+			log.Warnf("retention - unable to destroy snapshot: %s - %v", d.Snapshot.FullName, err)
+			failed = append(failed, d.Snapshot.Name)
+			continue
+		}
+		destroyed = append(destroyed, d.Snapshot.Name)
+	}
+
+	if len(failed) > 0 {
+		return destroyed, fmt.Errorf("unable to destroy snapshot(s): %s", strings.Join(failed, ", "))
+	}
+	return destroyed, nil
+}
@@ -0,0 +1,135 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"github.com/j-keck/zfs-snap-diff/pkg/config"
+	"github.com/j-keck/zfs-snap-diff/pkg/zfs"
+	"github.com/j-keck/zfs-snap-diff/pkg/zfs/zfstest"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// hourlySnapshots returns 'n' hourly snapshots - newest first
+func hourlySnapshots(n int, newest time.Time) zfs.Snapshots {
+	snaps := zfs.Snapshots{}
+	for i := 0; i < n; i++ {
+		created := newest.Add(time.Duration(-i) * time.Hour)
+		snaps = append(snaps, zfs.Snapshot{Name: fmt.Sprintf("hourly-%s", created.Format("2006-01-02T15")),
+			Created: created})
+	}
+	return snaps
+}
+
+func TestCompute(t *testing.T) {
+	newest := time.Date(2020, 3, 10, 12, 0, 0, 0, time.UTC)
+	snaps := hourlySnapshots(24*5, newest)
+
+	plan := Compute(config.RetentionPolicy{Hourly: 6, Daily: 3}, snaps)
+
+	// 6 hourly + the newest per day for the 2 days before today
+	expected := 8
+	if len(plan.Keep) != expected {
+		t.Errorf("%d snapshots kept - expected %d", len(plan.Keep), expected)
+	}
+
+	if len(plan.Keep)+len(plan.Destroy) != len(snaps) {
+		t.Errorf("plan does not cover all snapshots")
+	}
+
+	// the newest snapshot is the hourly and the daily snapshot
+	if r := plan.Keep[0].Reasons; len(r) != 2 || r[0] != "hourly" || r[1] != "daily" {
+		t.Errorf("unexpected reasons: %v", r)
+	}
+}
+
+func TestComputeKeepsHeldAndClonedSnapshots(t *testing.T) {
+	newest := time.Date(2020, 3, 10, 12, 0, 0, 0, time.UTC)
+	snaps := hourlySnapshots(10, newest)
+	snaps[8].Holds = 1
+	snaps[9].Clones = []string{"tank/clone"}
+
+	plan := Compute(config.RetentionPolicy{Hourly: 2}, snaps)
+
+	expected := 4
+	if len(plan.Keep) != expected {
+		t.Errorf("%d snapshots kept - expected %d", len(plan.Keep), expected)
+	}
+
+	for _, d := range plan.Destroy {
+		if d.Snapshot.Holds > 0 || len(d.Snapshot.Clones) > 0 {
+			t.Errorf("snapshot with hold / clone in destroy list: %s", d.Snapshot.Name)
+		}
+	}
+}
+
+func TestComputeNamePattern(t *testing.T) {
+	newest := time.Date(2020, 3, 10, 12, 0, 0, 0, time.UTC)
+	snaps := append(hourlySnapshots(5, newest), zfs.Snapshot{Name: "manual", Created: newest})
+
+	plan := Compute(config.RetentionPolicy{Hourly: 1, NamePattern: "hourly-*"}, snaps)
+	if len(plan.Keep)+len(plan.Destroy) != 5 {
+		t.Errorf("snapshot with a not matching name in the plan")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(config.RetentionPolicy{}); err == nil {
+		t.Error("policy without rules accepted")
+	}
+
+	if err := Validate(config.RetentionPolicy{Daily: 1, NamePattern: "["}); err == nil {
+		t.Error("invalid name-pattern accepted")
+	}
+}
+
+func TestApply(t *testing.T) {
+	ctx := context.Background()
+	root, err := ioutil.TempDir("", "zsd-retention")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	fake := zfstest.NewZFSCmdFake(root)
+	if _, err := fake.CreateDataset("tank"); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zfs.NewZFSWithCmd(fake, "tank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds := z.Datasets().Root()
+
+	for _, name := range []string{"auto-1", "auto-2", "auto-3", "manual"} {
+		if _, err := ds.CreateSnapshot(ctx, name, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ds.HoldSnapshot(ctx, "auto-1", "keep", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := PlanForDataset(ctx, *ds, config.RetentionPolicy{NamePattern: "auto-*", Yearly: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	destroyed, err := plan.Apply(ctx, *ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(destroyed) != 1 || destroyed[0] != "auto-2" {
+		t.Errorf("unexpected destroyed snapshots: %v", destroyed)
+	}
+
+	snaps, _ := ds.ScanSnapshots(ctx)
+	if len(snaps) != 3 {
+		t.Errorf("%d snapshots left - expected 3", len(snaps))
+	}
+}
@@ -1,6 +1,7 @@
 package scanner
 
 import (
+	"context"
 	"github.com/j-keck/plog"
 	"github.com/j-keck/zfs-snap-diff/pkg/config"
 	"github.com/j-keck/zfs-snap-diff/pkg/fs"
@@ -17,6 +18,8 @@ type Scanner struct {
 	compareMethod string
 	dataset       zfs.Dataset
 	zfs           zfs.ZFS
+	// query restricts the scanned snapshots - an empty query scans all snapshots
+	query zfs.SnapshotQuery
 }
 
 type ScanResult struct {
@@ -35,11 +38,14 @@ type FileVersion struct {
 	Snapshot zfs.Snapshot  `json:"snapshot"`
 }
 
-func NewScanner(dateRange DateRange, compareMethod string, dataset zfs.Dataset, zfs zfs.ZFS) Scanner {
-	return Scanner{dateRange, compareMethod, dataset, zfs}
+func NewScanner(dateRange DateRange, compareMethod string, dataset zfs.Dataset, zfs zfs.ZFS, query zfs.SnapshotQuery) Scanner {
+	return Scanner{dateRange, compareMethod, dataset, zfs, query}
 }
 
-func (self *Scanner) FindFileVersions(pathCurrentVersion string) (ScanResult, error) {
+// FindFileVersions searches the snapshots for changed versions of the given file.
+//
+// The scan stops when the context is done.
+func (self *Scanner) FindFileVersions(ctx context.Context, pathCurrentVersion string) (ScanResult, error) {
 	sr := ScanResult{FileVersions: make([]FileVersion, 0), DateRange: self.dateRange}
 	startTs := time.Now()
 
@@ -48,7 +54,7 @@ func (self *Scanner) FindFileVersions(pathCurrentVersion string) (ScanResult, er
 		return ScanResult{}, err
 	}
 
-	snaps, err := self.dataset.ScanSnapshots()
+	snaps, err := self.dataset.QuerySnapshots(ctx, self.query)
 	if err != nil {
 		return ScanResult{}, err
 	}
@@ -57,7 +63,12 @@ func (self *Scanner) FindFileVersions(pathCurrentVersion string) (ScanResult, er
 		pathCurrentVersion, self.dateRange.String())
 	var cmp Comparator
 	snapsSkipped := 0
+	mountCheck := zfs.NewMountCheck()
 	for idx, snap := range snaps {
+		if err := ctx.Err(); err != nil {
+			log.Debugf("abort search - %v", err)
+			return sr, err
+		}
 
 		// search is data-range based - check if the current checked snapshot
 		// was created in the given range
@@ -77,13 +88,13 @@ func (self *Scanner) FindFileVersions(pathCurrentVersion string) (ScanResult, er
 
 		// mount the snapshot if necessary
 		if config.Get.ZFS.MountSnapshots {
-			isMounted, err := snap.IsMounted()
+			isMounted, err := mountCheck(&snap)
 			if err != nil {
 				log.Errorf("unable to check if snapshot: %s is mounted - %v", snap.Name, err)
 			}
 
 			if !isMounted {
-				if err := self.zfs.MountSnapshot(snap); err != nil {
+				if err := self.zfs.MountSnapshot(ctx, snap); err != nil {
 					log.Errorf("unable to mount snapshot: %s - %v", snap.Name, err)
 
 					// skip this snapshot
@@ -0,0 +1,67 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"github.com/j-keck/zfs-snap-diff/pkg/zfs"
+	"github.com/j-keck/zfs-snap-diff/pkg/zfs/zfstest"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFindFileVersions(t *testing.T) {
+	ctx := context.Background()
+	root, err := ioutil.TempDir("", "zsd-scanner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	fake := zfstest.NewZFSCmdFake(root)
+	mountPoint, err := fake.CreateDataset("tank")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zfs.NewZFSWithCmd(fake, "tank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds, _ := z.FindDatasetByName("tank")
+
+	file := filepath.Join(mountPoint, "file.txt")
+	for idx, content := range []string{"v1", "v2", "v2", "v3"} {
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ds.CreateSnapshot(ctx, fmt.Sprintf("snap-%d", idx), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ioutil.WriteFile(file, []byte("current"), 0644)
+
+	sc := NewScanner(NDaysBack(1, time.Now()), "md5", ds, z, zfs.SnapshotQuery{})
+	sr, err := sc.FindFileVersions(ctx, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := 3
+	if len(sr.FileVersions) != expected {
+		t.Errorf("%d file versions found - expected %d", len(sr.FileVersions), expected)
+	}
+
+	if sr.SnapsScanned != 4 {
+		t.Errorf("%d snapshots scanned - expected 4", sr.SnapsScanned)
+	}
+
+	// an aborted request stops the scan
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := sc.FindFileVersions(cancelled, file); err != context.Canceled {
+		t.Errorf("unexpected error from a cancelled scan: %v", err)
+	}
+}
@@ -0,0 +1,250 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"github.com/j-keck/zfs-snap-diff/pkg/config"
+	"github.com/j-keck/zfs-snap-diff/pkg/fs"
+	"github.com/j-keck/zfs-snap-diff/pkg/zfs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DiffSnapshots returns the changes between the snapshot 'from' and the snapshot 'to'.
+// If 'to' is empty, the snapshot is compared against the current filesystem state.
+//
+// It uses 'zfs diff' and falls back to walk the snapshot directories
+// if 'zfs diff' is not permitted (it needs the 'diff' delegation or root).
+// Both backends return the same result shape. All other errors are returned.
+func (self *Scanner) DiffSnapshots(ctx context.Context, from, to string) (zfs.FileChanges, error) {
+	changes, err := self.dataset.DiffSnapshots(ctx, from, to)
+	if err == nil || zfs.KindOf(err) != zfs.ErrorPermissionDenied {
+		return changes, err
+	}
+
+	log.Debugf("'zfs diff' not permitted - walk the snapshot directories - err: %v", err)
+	return self.WalkDiffSnapshots(ctx, from, to)
+}
+
+// WalkDiffSnapshots returns the changes between the snapshot 'from' and the snapshot 'to'
+// by walking the snapshot mount points. If 'to' is empty, the snapshot is compared
+// against the current filesystem state.
+//
+// Modified files are detected per the configured compare method.
+// Renames can't be detected - they are reported as a removed and a added entry.
+func (self *Scanner) WalkDiffSnapshots(ctx context.Context, from, to string) (zfs.FileChanges, error) {
+	fromSnap, err := self.lookupSnapshot(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+
+	toPath := self.dataset.MountPoint.Path
+	if len(to) > 0 {
+		toSnap, err := self.lookupSnapshot(ctx, to)
+		if err != nil {
+			return nil, err
+		}
+		toPath = toSnap.MountPoint.Path
+	}
+
+	// mount points from other datasets are not part of this dataset.
+	// locked datasets are not mounted.
+	var skip []string
+	for _, ds := range self.zfs.Datasets() {
+		if ds.Name != self.dataset.Name && !ds.Locked {
+			skip = append(skip, ds.MountPoint.Path)
+		}
+	}
+
+	log.Debugf("walk snapshot diff: %s -> %s", fromSnap.MountPoint.Path, toPath)
+	w := diffWalker{ctx, self.compareMethod, self.dataset.MountPoint.Path, skip, zfs.FileChanges{}}
+	if err := w.walk(fromSnap.MountPoint.Path, toPath, ""); err != nil {
+		return nil, err
+	}
+	return w.changes, nil
+}
+
+func (self *Scanner) lookupSnapshot(ctx context.Context, name string) (zfs.Snapshot, error) {
+	// accept the full snapshot name
+	fields := strings.Split(name, "@")
+	name = fields[len(fields)-1]
+
+	snaps, err := self.dataset.ScanSnapshots(ctx)
+	if err != nil {
+		return zfs.Snapshot{}, err
+	}
+
+	for _, snap := range snaps {
+		if snap.Name == name {
+			// mount the snapshot if necessary
+			if config.Get.ZFS.MountSnapshots {
+				if isMounted, _ := snap.IsMounted(); !isMounted {
+					if err := self.zfs.MountSnapshot(ctx, snap); err != nil {
+						return snap, err
+					}
+				}
+			}
+			return snap, nil
+		}
+	}
+	return zfs.Snapshot{}, fmt.Errorf("snapshot: '%s' not found in dataset: '%s'", name, self.dataset.Name)
+}
+
+type diffWalker struct {
+	// ctx aborts the walk - it's checked per directory entry
+	ctx           context.Context
+	compareMethod string
+	// basePath is used to report the paths in the same form as 'zfs diff'
+	basePath string
+	skip     []string
+	changes  zfs.FileChanges
+}
+
+// walk compares the directory 'from' with the directory 'to'.
+// both directory listings are read in parallel.
+func (self *diffWalker) walk(from, to, rel string) error {
+	type result struct {
+		entries map[string]fs.FSHandle
+		err     error
+	}
+
+	ls := func(p string) chan result {
+		c := make(chan result, 1)
+		go func() {
+			dh := fs.DirHandle{FSHandle: fs.FSHandle{Path: p}}
+			ls, err := dh.Ls()
+			entries := make(map[string]fs.FSHandle, len(ls))
+			for _, e := range ls {
+				entries[e.Name] = e
+			}
+			c <- result{entries, err}
+		}()
+		return c
+	}
+
+	fromC, toC := ls(from), ls(to)
+	fromR, toR := <-fromC, <-toC
+	if fromR.err != nil {
+		return fromR.err
+	}
+	if toR.err != nil {
+		return toR.err
+	}
+
+	// process the entries sorted by name
+	var names []string
+	for name := range fromR.entries {
+		names = append(names, name)
+	}
+	for name := range toR.entries {
+		if _, ok := fromR.entries[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := self.ctx.Err(); err != nil {
+			return err
+		}
+
+		if rel == "" && name == ".zfs" {
+			continue
+		}
+
+		relPath := path.Join(rel, name)
+		if self.isSkipped(relPath) {
+			log.Tracef("skip mount point from other dataset: %s", relPath)
+			continue
+		}
+
+		f, inFrom := fromR.entries[name]
+		t, inTo := toR.entries[name]
+		switch {
+		case inFrom && !inTo:
+			if err := self.report(zfs.Removed, f, relPath); err != nil {
+				return err
+			}
+		case !inFrom && inTo:
+			if err := self.report(zfs.Added, t, relPath); err != nil {
+				return err
+			}
+		case f.Kind != t.Kind:
+			if err := self.report(zfs.Removed, f, relPath); err != nil {
+				return err
+			}
+			if err := self.report(zfs.Added, t, relPath); err != nil {
+				return err
+			}
+		case f.Kind == fs.DIR:
+			if !f.MTime.Equal(t.MTime) {
+				self.add(zfs.Modified, t, relPath)
+			}
+			if err := self.walk(f.Path, t.Path, relPath); err != nil {
+				return err
+			}
+		default:
+			if self.hasChanged(f, t) {
+				self.add(zfs.Modified, t, relPath)
+			}
+		}
+	}
+	return nil
+}
+
+// report adds the given entry - and for directories all children - with the given change
+func (self *diffWalker) report(change zfs.ChangeType, h fs.FSHandle, rel string) error {
+	self.add(change, h, rel)
+	if h.Kind != fs.DIR {
+		return nil
+	}
+
+	ls, err := (&fs.DirHandle{FSHandle: h}).Ls()
+	if err != nil {
+		return err
+	}
+	for _, e := range ls {
+		if err := self.ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := self.report(change, e, path.Join(rel, e.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (self *diffWalker) add(change zfs.ChangeType, h fs.FSHandle, rel string) {
+	p := path.Join(self.basePath, rel)
+	log.Tracef("%s: %s", change, p)
+	self.changes = append(self.changes, zfs.FileChange{Change: change, Kind: h.Kind, Path: p, CTime: h.MTime})
+}
+
+func (self *diffWalker) hasChanged(from, to fs.FSHandle) bool {
+	if from.Size != to.Size {
+		return true
+	}
+
+	if from.Kind != fs.FILE {
+		return !from.MTime.Equal(to.MTime)
+	}
+
+	cmp, err := NewComparator(self.compareMethod, fs.FileHandle{FSHandle: from})
+	if err != nil {
+		log.Warnf("unable to compare: %s - %v", from.Path, err)
+		return true
+	}
+	return cmp.HasChanged(fs.FileHandle{FSHandle: to})
+}
+
+func (self *diffWalker) isSkipped(rel string) bool {
+	p := path.Join(self.basePath, rel)
+	for _, s := range self.skip {
+		if p == s {
+			return true
+		}
+	}
+	return false
+}
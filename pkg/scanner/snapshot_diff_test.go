@@ -0,0 +1,89 @@
+package scanner
+
+import (
+	"context"
+	"github.com/j-keck/zfs-snap-diff/pkg/zfs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiffWalker(t *testing.T) {
+	base, err := ioutil.TempDir("", "zsd-diff-walker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+
+	write := func(p, content string) {
+		p = filepath.Join(base, p)
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("from/unchanged", "same")
+	write("from/modified", "old content")
+	write("from/removed", "removed")
+	write("from/dir/removed", "removed")
+	write("to/unchanged", "same")
+	write("to/modified", "new content")
+	write("to/added/file", "added")
+	os.MkdirAll(filepath.Join(base, "to/dir"), 0755)
+
+	w := diffWalker{context.Background(), "md5", "/tank", nil, zfs.FileChanges{}}
+	if err := w.walk(filepath.Join(base, "from"), filepath.Join(base, "to"), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]zfs.ChangeType{
+		"/tank/added":       zfs.Added,
+		"/tank/added/file":  zfs.Added,
+		"/tank/dir/removed": zfs.Removed,
+		"/tank/modified":    zfs.Modified,
+		"/tank/removed":     zfs.Removed,
+	}
+
+	found := make(map[string]zfs.ChangeType)
+	for _, c := range w.changes {
+		// directory mtimes are not stable in this test
+		if c.Path == "/tank/dir" {
+			continue
+		}
+		found[c.Path] = c.Change
+	}
+
+	if len(found) != len(expected) {
+		t.Errorf("%d changes found - expected %d: %v", len(found), len(expected), found)
+	}
+
+	for p, change := range expected {
+		if found[p] != change {
+			t.Errorf("unexpected change for %s: '%s' - expected: '%s'", p, found[p], change)
+		}
+	}
+}
+
+func TestDiffWalkerCancel(t *testing.T) {
+	base, err := ioutil.TempDir("", "zsd-diff-walker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+
+	os.MkdirAll(filepath.Join(base, "from"), 0755)
+	os.MkdirAll(filepath.Join(base, "to/added"), 0755)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	w := diffWalker{ctx, "md5", "/tank", nil, zfs.FileChanges{}}
+	if err := w.walk(filepath.Join(base, "from"), filepath.Join(base, "to"), ""); err != context.Canceled {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(w.changes) != 0 {
+		t.Errorf("walk not aborted - changes: %v", w.changes)
+	}
+}
@@ -0,0 +1,239 @@
+// Package scheduler creates periodic snapshots per configured schedules.
+//
+// The snapshots are created on multiples of the interval in the
+// local time - a schedule with an one hour interval creates the
+// snapshots at the full hour, a daily schedule at midnight.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/j-keck/plog"
+	"github.com/j-keck/zfs-snap-diff/pkg/config"
+	"github.com/j-keck/zfs-snap-diff/pkg/zfs"
+	"sort"
+	"sync"
+	"time"
+)
+
+var log = plog.GlobalLogger()
+
+// MinInterval is the smallest supported schedule interval
+const MinInterval = time.Minute
+
+// Job is a snapshot schedule with the state from the last run
+type Job struct {
+	Schedule     config.SnapshotSchedule `json:"schedule"`
+	LastRun      time.Time               `json:"lastRun"`
+	LastSnapshot string                  `json:"lastSnapshot"`
+	LastError    string                  `json:"lastError"`
+	NextRun      time.Time               `json:"nextRun"`
+	dataset      zfs.Dataset
+}
+
+// Scheduler creates snapshots per schedule
+type Scheduler struct {
+	mutex  sync.Mutex
+	jobs   []*Job
+	wakeup chan struct{}
+}
+
+// NewScheduler creates a scheduler without any job
+func NewScheduler() *Scheduler {
+	return &Scheduler{wakeup: make(chan struct{}, 1)}
+}
+
+// NewSchedulerFromConfig creates a scheduler for the configured
+// schedules from datasets in the given zfs handler.
+//
+// Schedules for datasets outside of the zfs handler are ignored.
+func NewSchedulerFromConfig(z zfs.ZFS) (*Scheduler, error) {
+	self := NewScheduler()
+	for _, schedule := range config.Get.SnapshotSchedules {
+		if !z.Contains(schedule.DatasetName) {
+			log.Infof("ignore snapshot schedule for dataset: %s - not served", schedule.DatasetName)
+			continue
+		}
+
+		ds, err := z.FindDatasetByName(schedule.DatasetName)
+		if err != nil {
+			return nil, fmt.Errorf("invalid snapshot schedule - %v", err)
+		}
+
+		if err := self.Add(ds, schedule); err != nil {
+			return nil, err
+		}
+	}
+	return self, nil
+}
+
+// Add adds a schedule for the given dataset
+func (self *Scheduler) Add(ds zfs.Dataset, schedule config.SnapshotSchedule) error {
+	if schedule.Interval.Duration < MinInterval {
+		return fmt.Errorf("invalid snapshot schedule for dataset: %s - interval '%s' is smaller than %s",
+			ds.Name, schedule.Interval, MinInterval)
+	}
+
+	if err := zfs.ValidateSnapshotNameTemplate(nameTemplate(schedule)); err != nil {
+		return fmt.Errorf("invalid snapshot schedule for dataset: %s - %v", ds.Name, err)
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	job := &Job{Schedule: schedule, dataset: ds}
+	job.NextRun = nextRun(schedule, time.Now())
+	log.Infof("snapshot schedule for dataset: %s every %s - next run at: %s",
+		ds.Name, schedule.Interval, job.NextRun.Format(time.RFC3339))
+	self.jobs = append(self.jobs, job)
+
+	// wake up 'Run' - the new job can be due before the current next run
+	select {
+	case self.wakeup <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Jobs returns a snapshot of the jobs sorted by the next run
+func (self *Scheduler) Jobs() []Job {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	jobs := []Job{}
+	for _, job := range self.jobs {
+		jobs = append(jobs, *job)
+	}
+
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].NextRun.Before(jobs[j].NextRun)
+	})
+	return jobs
+}
+
+// Run executes the jobs when they are due.
+// It blocks until the given context is done - without any jobs, it waits for new jobs.
+func (self *Scheduler) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+
+	for {
+		var due <-chan time.Time
+		if next, err := self.nextRun(); err == nil {
+			timer.Reset(time.Until(next))
+			due = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Debug("snapshot scheduler stopped")
+			return
+		case <-self.wakeup:
+			log.Trace("snapshot scheduler woken up")
+		case <-due:
+			self.runDue(ctx, time.Now())
+			continue
+		}
+
+		// drain the timer before it gets reset
+		if due != nil && !timer.Stop() {
+			<-timer.C
+		}
+	}
+}
+
+// nextRun returns the earliest next run from all jobs
+func (self *Scheduler) nextRun() (time.Time, error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if len(self.jobs) == 0 {
+		return time.Time{}, errors.New("no jobs")
+	}
+
+	next := self.jobs[0].NextRun
+	for _, job := range self.jobs[1:] {
+		if job.NextRun.Before(next) {
+			next = job.NextRun
+		}
+	}
+	return next, nil
+}
+
+// runDue creates the snapshots from all due jobs.
+//
+// The snapshots are created without holding the lock, so
+// 'Jobs' is not blocked by long running zfs commands.
+func (self *Scheduler) runDue(ctx context.Context, now time.Time) {
+	self.mutex.Lock()
+	var due []*Job
+	for _, job := range self.jobs {
+		if !now.Before(job.NextRun) {
+			due = append(due, job)
+		}
+	}
+	self.mutex.Unlock()
+
+	for _, job := range due {
+		name, err := job.run(ctx, now)
+		if err == nil {
+			log.Infof("scheduled snapshot created: %s", name)
+		} else {
+			log.Errorf("unable to create scheduled snapshot for dataset: %s - %v", job.dataset.Name, err)
+		}
+
+		self.mutex.Lock()
+		job.LastRun = now
+		job.LastSnapshot, job.LastError = name, ""
+		if err != nil {
+			job.LastSnapshot, job.LastError = "", err.Error()
+		}
+		job.NextRun = nextRun(job.Schedule, now)
+		self.mutex.Unlock()
+	}
+}
+
+// run creates the snapshot - the schedule and the dataset are not modified after 'Add'
+func (self *Job) run(ctx context.Context, now time.Time) (string, error) {
+	name, err := self.dataset.SnapshotNameFromTemplate(nameTemplate(self.Schedule), now)
+	if err != nil {
+		return "", err
+	}
+
+	var flags []string
+	if self.Schedule.Recursive {
+		flags = append(flags, "-r")
+	}
+	return self.dataset.CreateSnapshot(ctx, name, flags, nil)
+}
+
+func nameTemplate(schedule config.SnapshotSchedule) string {
+	if len(schedule.NameTemplate) > 0 {
+		return schedule.NameTemplate
+	}
+	return config.Get.SnapshotNameTemplate
+}
+
+// nextRun returns the next multiple of the schedule interval after 'now'.
+//
+// The multiples are aligned to the local time - a daily schedule
+// runs at the local midnight, also after a daylight saving time change.
+func nextRun(schedule config.SnapshotSchedule, now time.Time) time.Time {
+	interval := schedule.Interval.Duration
+	now = now.In(time.Local)
+
+	_, offset := now.Zone()
+	shift := time.Duration(offset) * time.Second
+	next := now.Add(shift).Truncate(interval).Add(interval).Add(-shift)
+
+	// the offset changes between now and the next run
+	if _, nextOffset := next.Zone(); nextOffset != offset {
+		corrected := next.Add(shift - time.Duration(nextOffset)*time.Second)
+		if corrected.After(now) {
+			next = corrected
+		}
+	}
+	return next
+}
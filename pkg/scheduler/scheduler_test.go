@@ -0,0 +1,144 @@
+package scheduler
+
+import (
+	"context"
+	"github.com/j-keck/zfs-snap-diff/pkg/config"
+	"github.com/j-keck/zfs-snap-diff/pkg/zfs"
+	"github.com/j-keck/zfs-snap-diff/pkg/zfs/zfstest"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNextRun(t *testing.T) {
+	local := time.Local
+	defer func() { time.Local = local }()
+	time.Local = time.FixedZone("UTC+2", 2*60*60)
+
+	for interval, expected := range map[time.Duration]time.Time{
+		15 * time.Minute: time.Date(2020, 3, 9, 14, 15, 0, 0, time.Local),
+		24 * time.Hour:   time.Date(2020, 3, 10, 0, 0, 0, 0, time.Local),
+	} {
+		schedule := config.SnapshotSchedule{Interval: config.Duration{Duration: interval}}
+		now := time.Date(2020, 3, 9, 14, 5, 7, 0, time.Local)
+		if next := nextRun(schedule, now); !next.Equal(expected) {
+			t.Errorf("unexpected next run for interval %s: %s - expected: %s", interval, next, expected)
+		}
+	}
+
+	// daylight saving time change
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("timezone database not available - %v", err)
+	}
+	time.Local = berlin
+
+	schedule := config.SnapshotSchedule{Interval: config.Duration{Duration: 24 * time.Hour}}
+	next := nextRun(schedule, time.Date(2020, 3, 29, 0, 0, 0, 0, time.Local))
+	if expected := time.Date(2020, 3, 30, 0, 0, 0, 0, time.Local); !next.Equal(expected) {
+		t.Errorf("unexpected next run after the dst change: %s - expected: %s", next, expected)
+	}
+}
+
+func TestRunStops(t *testing.T) {
+	sched := NewScheduler()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		sched.Run(ctx)
+		close(done)
+	}()
+
+	// a new job wakes up the scheduler
+	err := sched.Add(zfs.Dataset{Name: "tank"}, config.SnapshotSchedule{
+		Interval: config.Duration{Duration: time.Hour},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("scheduler not stopped")
+	}
+}
+
+func TestRunDue(t *testing.T) {
+	root, err := ioutil.TempDir("", "zsd-scheduler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	fake := zfstest.NewZFSCmdFake(root)
+	if _, err := fake.CreateDataset("tank/fs1"); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zfs.NewZFSWithCmd(fake, "tank")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sched := NewScheduler()
+	err = sched.Add(*z.Datasets().Root(), config.SnapshotSchedule{
+		Interval:     config.Duration{Duration: time.Hour},
+		Recursive:    true,
+		NameTemplate: "auto-%FT%H:%M",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// not due
+	now := time.Now()
+	sched.runDue(context.Background(), now)
+	if job := sched.Jobs()[0]; !job.LastRun.IsZero() {
+		t.Errorf("job executed before it was due")
+	}
+
+	// due
+	now = sched.Jobs()[0].NextRun
+	sched.runDue(context.Background(), now)
+	job := sched.Jobs()[0]
+	if job.LastError != "" {
+		t.Fatalf("job failed: %s", job.LastError)
+	}
+
+	expected := "tank@" + now.Format("auto-2006-01-02T15:04")
+	if job.LastSnapshot != expected {
+		t.Errorf("unexpected snapshot: %s - expected: %s", job.LastSnapshot, expected)
+	}
+
+	if !job.NextRun.Equal(now.Add(time.Hour)) {
+		t.Errorf("unexpected next run: %s", job.NextRun)
+	}
+
+	// recursive snapshot
+	ds, _ := z.FindDatasetByName("tank/fs1")
+	if snaps, _ := ds.ScanSnapshots(context.Background()); len(snaps) != 1 {
+		t.Errorf("%d snapshots in the child dataset - expected 1", len(snaps))
+	}
+}
+
+func TestAddValidatesSchedule(t *testing.T) {
+	sched := NewScheduler()
+	err := sched.Add(zfs.Dataset{Name: "tank"}, config.SnapshotSchedule{
+		Interval: config.Duration{Duration: time.Second},
+	})
+	if err == nil {
+		t.Error("too small interval accepted")
+	}
+
+	err = sched.Add(zfs.Dataset{Name: "tank"}, config.SnapshotSchedule{
+		Interval:     config.Duration{Duration: time.Hour},
+		NameTemplate: "auto/%F",
+	})
+	if err == nil {
+		t.Error("invalid name template accepted")
+	}
+}
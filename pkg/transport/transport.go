@@ -0,0 +1,120 @@
+// Package transport executes commands - local or per a wrapper command.
+//
+// A wrapper is an arbitrary command (argv) which executes the given command
+// in another environment, like:
+//
+//   - `ssh host` on a remote host
+//   - `nsenter -t PID -m` in the mount namespace from a container
+//   - `machinectl shell container` in a systemd-nspawn container
+//
+// The transport is used for the zfs commands and for the file reads
+// from the snapshots, so that snapshots from datasets which are not
+// visible from the current host / namespace can be browsed.
+package transport
+
+import (
+	"context"
+	"github.com/j-keck/plog"
+	"github.com/j-keck/zfs-snap-diff/pkg/config"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var log = plog.GlobalLogger()
+
+// Transport creates commands which are executed local or per a wrapper
+type Transport interface {
+	// Command returns the command to execute the given program with the given arguments.
+	// The command gets killed when the context is done.
+	Command(ctx context.Context, name string, args ...string) *exec.Cmd
+	// IsLocal checks if the commands are executed local
+	IsLocal() bool
+	String() string
+}
+
+// Get returns the transport from the current configuration
+func Get() Transport {
+	return New(config.Get.Transport)
+}
+
+// New returns the transport for the given configuration
+func New(cfg config.TransportConfig) Transport {
+	if cfg.IsLocal() {
+		return Local{}
+	}
+	return Wrapper{Argv: cfg.Wrapper, Shell: cfg.Shell}
+}
+
+// Local executes the commands local
+type Local struct{}
+
+func (Local) Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, name, args...)
+}
+
+func (Local) IsLocal() bool {
+	return true
+}
+
+func (Local) String() string {
+	return "local"
+}
+
+// Wrapper executes the commands per the wrapper command
+type Wrapper struct {
+	// Argv is the wrapper command - like ["ssh", "host"]
+	Argv []string
+	// Shell quotes the arguments - necessary if the wrapper passes the command through a shell.
+	// the arguments are always quoted for wrappers which are known to use a shell (see 'usesShell').
+	Shell bool
+}
+
+func (self Wrapper) Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	quote := self.Shell || usesShell(self.Argv)
+	argv := append([]string{}, self.Argv...)
+	for _, arg := range append([]string{name}, args...) {
+		if quote {
+			arg = Quote(arg)
+		}
+		argv = append(argv, arg)
+	}
+	log.Tracef("wrapped command: %s", strings.Join(argv, " "))
+	return exec.CommandContext(ctx, argv[0], argv[1:]...)
+}
+
+func (self Wrapper) IsLocal() bool {
+	return false
+}
+
+func (self Wrapper) String() string {
+	return strings.Join(self.Argv, " ")
+}
+
+// usesShell checks if the wrapper is known to pass the command through a shell:
+// 'ssh' and 'machinectl shell'
+func usesShell(argv []string) bool {
+	if len(argv) == 0 {
+		return false
+	}
+
+	switch filepath.Base(argv[0]) {
+	case "ssh":
+		return true
+	case "machinectl":
+		for _, arg := range argv[1:] {
+			if arg == "shell" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Quote quotes the given argument for a posix shell
+func Quote(arg string) string {
+	if len(arg) > 0 && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=:,@%+") == "" {
+		return arg
+	}
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
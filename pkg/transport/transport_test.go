@@ -0,0 +1,66 @@
+package transport
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestLocal(t *testing.T) {
+	out, err := Local{}.Command(context.Background(), "echo", "a b", "c").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "a b c\n" {
+		t.Errorf("unexpected output: '%s'", out)
+	}
+}
+
+func TestWrapper(t *testing.T) {
+	// executes the wrapped command as given
+	direct := Wrapper{Argv: []string{"sh", "-c", `exec "$@"`, "--"}}
+
+	// joins the arguments and passes them through a shell - like 'ssh'
+	shell := Wrapper{Argv: []string{"sh", "-c", `eval "$*"`, "--"}, Shell: true}
+
+	for _, w := range []Wrapper{direct, shell} {
+		out, err := w.Command(context.Background(), "printf", "%s|", "a b", "it's", "$HOME", "*").Output()
+		if err != nil {
+			t.Fatalf("wrapper: '%s' - %v", w, err)
+		}
+		if expected := "a b|it's|$HOME|*|"; string(out) != expected {
+			t.Errorf("wrapper: '%s' - unexpected output: '%s', expected: '%s'", w, out, expected)
+		}
+	}
+}
+
+func TestUsesShell(t *testing.T) {
+	for _, argv := range [][]string{{"ssh", "host"}, {"/usr/bin/ssh", "-p", "22", "host"}, {"machinectl", "shell", "container"}} {
+		if !usesShell(argv) {
+			t.Errorf("wrapper: %v - expected to use a shell", argv)
+		}
+	}
+	for _, argv := range [][]string{{"nsenter", "-t", "1", "-m"}, {"machinectl", "-q", "container"}, {}} {
+		if usesShell(argv) {
+			t.Errorf("wrapper: %v - not expected to use a shell", argv)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	for arg, expected := range map[string]string{
+		"tank/fs@snap": "tank/fs@snap",
+		"":             "''",
+		"a b":          "'a b'",
+		"it's":         `'it'\''s'`,
+		"$(id)":        "'$(id)'",
+	} {
+		if quoted := Quote(arg); quoted != expected {
+			t.Errorf("unexpected quote for '%s': %s - expected: %s", arg, quoted, expected)
+		}
+	}
+
+	if !strings.HasPrefix(Quote("a;b"), "'") {
+		t.Error("';' not quoted")
+	}
+}
@@ -0,0 +1,185 @@
+package webapp
+
+import (
+	"fmt"
+	"github.com/j-keck/zfs-snap-diff/pkg/zfs"
+	"net/http"
+)
+
+/// responds with the permitted operations per dataset for the user which executes the zfs commands
+func (self *WebApp) capabilitiesHndl(w http.ResponseWriter, r *http.Request) {
+	if self.principal == nil {
+		msg := "Unable to determine the capabilities - the current user is unknown"
+		log.Error(msg)
+		respondError(w, msg, nil, 500)
+		return
+	}
+
+	datasets := make(map[string]zfs.Capabilities)
+	for _, ds := range self.zfs.Datasets() {
+		caps, err := ds.Capabilities(r.Context(), *self.principal)
+		if err != nil {
+			msg := fmt.Sprintf("Unable to determine the capabilities for dataset: %s - %v", ds.Name, err)
+			log.Error(msg)
+			respondError(w, msg, err, 500)
+			return
+		}
+		datasets[ds.Name] = caps
+	}
+
+	respond(w, r, struct {
+		Principal zfs.Principal               `json:"principal"`
+		Datasets  map[string]zfs.Capabilities `json:"datasets"`
+	}{*self.principal, datasets})
+}
+
+/// responds with the properties from the given dataset
+///
+/// expected payload: { datasetName: "name" }
+func (self *WebApp) datasetPropertiesHndl(w http.ResponseWriter, r *http.Request) {
+	// decode the payload
+	type Payload struct {
+		DatasetName string `json:"datasetName"`
+	}
+
+	payload, ok := decodeJsonPayload(w, r, &Payload{}).(*Payload)
+	if !ok {
+		return
+	}
+
+	// get the dataset
+	ds, err := self.zfs.FindDatasetByName(payload.DatasetName)
+	if err != nil {
+		msg := fmt.Sprintf("Dataset with name: %s not found - %v", payload.DatasetName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 400)
+		return
+	}
+
+	props, err := ds.GetProperties(r.Context())
+	if err != nil {
+		msg := fmt.Sprintf("Unable to get properties for Dataset: %s - %v", payload.DatasetName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 500)
+		return
+	}
+
+	respond(w, r, props)
+}
+
+/// sets a property on the given dataset
+///
+/// expected payload: { datasetName: "name", propertyName: "compression", propertyValue: "lz4" }
+///
+/// only the properties from 'zfs.EditableProperties' and user properties are editable.
+func (self *WebApp) setDatasetPropertyHndl(w http.ResponseWriter, r *http.Request) {
+	// decode the payload
+	type Payload struct {
+		DatasetName   string `json:"datasetName"`
+		PropertyName  string `json:"propertyName"`
+		PropertyValue string `json:"propertyValue"`
+	}
+
+	payload, ok := decodeJsonPayload(w, r, &Payload{}).(*Payload)
+	if !ok {
+		return
+	}
+
+	// get the dataset
+	ds, err := self.zfs.FindDatasetByName(payload.DatasetName)
+	if err != nil {
+		msg := fmt.Sprintf("Dataset with name: %s not found - %v", payload.DatasetName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 400)
+		return
+	}
+
+	if !zfs.IsEditableProperty(payload.PropertyName) {
+		msg := fmt.Sprintf("Property: %s is not editable", payload.PropertyName)
+		log.Error(msg)
+		respondError(w, msg, nil, 400)
+		return
+	}
+
+	if !self.checkPermitted(w, r, ds, zfs.SetPropertyOperation(payload.PropertyName)) {
+		return
+	}
+
+	err = ds.SetProperty(r.Context(), payload.PropertyName, payload.PropertyValue)
+	if err != nil {
+		msg := fmt.Sprintf("Unable to set property: %s=%s - %v",
+			payload.PropertyName, payload.PropertyValue, err)
+		log.Error(msg)
+		respondError(w, msg, err, 500)
+		return
+	}
+
+	msg := fmt.Sprintf("Property '%s=%s' set on dataset '%s'",
+		payload.PropertyName, payload.PropertyValue, payload.DatasetName)
+	log.Info(msg)
+	w.Write([]byte(msg))
+}
+
+/// loads the encryption key from a locked dataset and mounts it
+///
+/// expected payload: { datasetName: "name", passphrase: "secret" }
+///
+/// the passphrase is only necessary for datasets with 'keylocation=prompt' - it's never logged.
+func (self *WebApp) unlockDatasetHndl(w http.ResponseWriter, r *http.Request) {
+	// decode the payload
+	type Payload struct {
+		DatasetName string     `json:"datasetName"`
+		Passphrase  zfs.Secret `json:"passphrase"`
+	}
+
+	payload, ok := decodeJsonPayload(w, r, &Payload{}).(*Payload)
+	if !ok {
+		return
+	}
+
+	// get the dataset
+	ds, err := self.zfs.FindDatasetByName(payload.DatasetName)
+	if err != nil {
+		msg := fmt.Sprintf("Dataset with name: %s not found - %v", payload.DatasetName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 400)
+		return
+	}
+
+	if !ds.IsEncrypted() {
+		msg := fmt.Sprintf("Dataset: %s is not encrypted", payload.DatasetName)
+		log.Error(msg)
+		respondError(w, msg, nil, 400)
+		return
+	}
+
+	if !self.checkPermitted(w, r, ds, "load-key") {
+		return
+	}
+
+	// the key can be loaded, but the dataset not mounted
+	if ds.KeyStatus != "available" {
+		if err := ds.LoadKey(r.Context(), payload.Passphrase); err != nil {
+			msg := fmt.Sprintf("Unable to load the key for dataset: %s - %v", payload.DatasetName, err)
+			log.Error(msg)
+			respondError(w, msg, err, 500)
+			return
+		}
+	}
+
+	if err := ds.Mount(r.Context()); err != nil {
+		msg := fmt.Sprintf("Unable to mount dataset: %s - %v", payload.DatasetName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 500)
+		return
+	}
+
+	// the mountpoint is accessible now
+	if err := self.zfs.RescanDatasets(r.Context()); err != nil {
+		log.Warnf("unable to rescan datasets - %v", err)
+	}
+
+	msg := fmt.Sprintf("Dataset '%s' unlocked", payload.DatasetName)
+	log.Info(msg)
+	w.Write([]byte(msg))
+}
@@ -0,0 +1,184 @@
+package webapp
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/j-keck/zfs-snap-diff/pkg/zfs"
+	"github.com/j-keck/zfs-snap-diff/pkg/zfs/zfstest"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDatasetPropertyHandlers(t *testing.T) {
+	app, cleanup := newTestWebApp(t)
+	defer cleanup()
+
+	if w := post(app.setDatasetPropertyHndl,
+		`{"datasetName": "tank/fs1", "propertyName": "snapdir", "propertyValue": "visible"}`); w.Code != 200 {
+		t.Fatalf("set property failed: %d - %s", w.Code, w.Body)
+	}
+
+	if w := post(app.setDatasetPropertyHndl,
+		`{"datasetName": "tank/fs1", "propertyName": "mountpoint", "propertyValue": "/tmp"}`); w.Code != 400 {
+		t.Errorf("not editable property accepted: %d - %s", w.Code, w.Body)
+	}
+
+	w := post(app.datasetPropertiesHndl, `{"datasetName": "tank/fs1"}`)
+	var props zfs.Properties
+	if err := json.Unmarshal(w.Body.Bytes(), &props); err != nil {
+		t.Fatal(err)
+	}
+
+	if p, ok := props.Get("snapdir"); !ok || p.Value != "visible" || p.Source != "local" {
+		t.Errorf("unexpected property: %+v", p)
+	}
+}
+
+func TestCapabilitiesHandlers(t *testing.T) {
+	root, err := ioutil.TempDir("", "zsd-webapp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	fake := zfstest.NewZFSCmdFake(root)
+	if _, err := fake.CreateDataset("tank/fs1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := fake.Exec(context.Background(), "allow -u", "alice", "snapshot,mount,compression", "tank"); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zfs.NewZFSWithCmd(fake, "tank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := &WebApp{zfs: z, sends: NewProgresses(), principal: &zfs.Principal{User: "alice"}}
+
+	if w := post(app.createSnapshotHndl, `{"datasetName": "tank/fs1", "snapshotName": "one"}`); w.Code != 200 {
+		t.Fatalf("permitted create snapshot failed: %d - %s", w.Code, w.Body)
+	}
+
+	w := post(app.destroySnapshotHndl, `{"datasetName": "tank/fs1", "snapshotName": "one"}`)
+	if w.Code != 403 || !strings.Contains(w.Body.String(), "destroy") {
+		t.Errorf("not permitted destroy snapshot: %d - %s", w.Code, w.Body)
+	}
+
+	for name, hndl := range map[string]func(http.ResponseWriter, *http.Request){
+		"bookmark": app.createBookmarkHndl,
+		"release":  app.releaseSnapshotHndl,
+		"destroy":  app.applyRetentionHndl,
+	} {
+		w := post(hndl, `{"datasetName": "tank/fs1", "snapshotName": "one", "bookmarkName": "bm", "tag": "keep"}`)
+		if w.Code != 403 || !strings.Contains(w.Body.String(), name) {
+			t.Errorf("not permitted %s: %d - %s", name, w.Code, w.Body)
+		}
+	}
+
+	if w := post(app.setDatasetPropertyHndl,
+		`{"datasetName": "tank/fs1", "propertyName": "compression", "propertyValue": "lz4"}`); w.Code != 200 {
+		t.Errorf("permitted set property failed: %d - %s", w.Code, w.Body)
+	}
+
+	if w := post(app.setDatasetPropertyHndl,
+		`{"datasetName": "tank/fs1", "propertyName": "com.example:prop", "propertyValue": "x"}`); w.Code != 403 {
+		t.Errorf("not permitted set user property: %d - %s", w.Code, w.Body)
+	}
+
+	// without the 'diff' permission, the snapshot directories are compared
+	if w := post(app.snapshotDiffHndl, `{"datasetName": "tank/fs1", "fromSnapshotName": "one"}`); w.Code != 200 {
+		t.Errorf("snapshot diff failed: %d - %s", w.Code, w.Body)
+	}
+
+	w = post(app.capabilitiesHndl, "")
+	var resp struct {
+		Datasets map[string]zfs.Capabilities `json:"datasets"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if caps := resp.Datasets["tank/fs1"]; !caps["snapshot"].Permitted || caps["destroy"].Permitted {
+		t.Errorf("unexpected capabilities: %+v", caps)
+	}
+}
+
+func TestUnlockDatasetHandler(t *testing.T) {
+	root, err := ioutil.TempDir("", "zsd-webapp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	fake := zfstest.NewZFSCmdFake(root)
+	if _, err := fake.CreateEncryptedDataset("tank/home", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zfs.NewZFSWithCmd(fake, "tank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := &WebApp{zfs: z, sends: NewProgresses()}
+
+	// locked datasets are reported
+	w := post(app.rescanDatasetsHndl, `{}`)
+	var datasets zfs.Datasets
+	if err := json.Unmarshal(w.Body.Bytes(), &datasets); err != nil {
+		t.Fatalf("%v - %s", err, w.Body)
+	}
+	if len(datasets) != 2 || !datasets[1].Locked {
+		t.Fatalf("locked dataset not reported: %+v", datasets)
+	}
+
+	if w := post(app.unlockDatasetHndl, `{"datasetName": "tank", "passphrase": "secret"}`); w.Code != 400 {
+		t.Errorf("unlock a not encrypted dataset: %d - %s", w.Code, w.Body)
+	}
+
+	w = post(app.unlockDatasetHndl, `{"datasetName": "tank/home", "passphrase": "wrong"}`)
+	if w.Code != 403 || strings.Contains(w.Body.String(), "wrong") {
+		t.Errorf("unlock with a wrong passphrase: %d - %s", w.Code, w.Body)
+	}
+
+	if w := post(app.unlockDatasetHndl, `{"datasetName": "tank/home", "passphrase": "secret"}`); w.Code != 200 {
+		t.Fatalf("unlock failed: %d - %s", w.Code, w.Body)
+	}
+
+	if ds, _ := app.zfs.FindDatasetByName("tank/home"); ds.Locked {
+		t.Errorf("dataset still locked: %+v", ds)
+	}
+}
+
+func TestClonePermissions(t *testing.T) {
+	root, err := ioutil.TempDir("", "zsd-webapp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	fake := zfstest.NewZFSCmdFake(root)
+	if _, err := fake.CreateDataset("tank/fs1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, cmd := range []string{"snapshot tank/fs1@one", "clone tank/fs1@one tank/restore", "allow -u alice destroy,promote,mount tank/fs1"} {
+		if _, _, err := fake.Exec(context.Background(), cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	z, err := zfs.NewZFSWithCmd(fake, "tank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := &WebApp{zfs: z, sends: NewProgresses(), principal: &zfs.Principal{User: "alice"}}
+
+	// the permissions on the origin are not sufficient
+	for name, hndl := range map[string]http.HandlerFunc{"destroy": app.destroyCloneHndl, "promote": app.promoteCloneHndl} {
+		w := post(hndl, `{"datasetName": "tank/fs1", "cloneName": "tank/restore"}`)
+		if w.Code != 403 || !strings.Contains(w.Body.String(), "tank/restore") {
+			t.Errorf("not permitted %s clone: %d - %s", name, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package webapp
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Progress tracks a running stream transfer (like a 'zfs send' download)
+type Progress struct {
+	Id            int64     `json:"id"`
+	Name          string    `json:"name"`
+	Started       time.Time `json:"started"`
+	Transferred   uint64    `json:"transferred"`
+	EstimatedSize uint64    `json:"estimatedSize"`
+}
+
+// Write counts the transferred bytes
+func (self *Progress) Write(p []byte) (int, error) {
+	atomic.AddUint64(&self.Transferred, uint64(len(p)))
+	return len(p), nil
+}
+
+// Progresses are all running transfers
+type Progresses struct {
+	mutex   sync.Mutex
+	nextId  int64
+	running map[int64]*Progress
+}
+
+func NewProgresses() *Progresses {
+	return &Progresses{running: make(map[int64]*Progress)}
+}
+
+// Start registers a new transfer
+func (self *Progresses) Start(name string, estimatedSize uint64) *Progress {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.nextId++
+	p := &Progress{Id: self.nextId, Name: name, Started: time.Now(), EstimatedSize: estimatedSize}
+	self.running[p.Id] = p
+	return p
+}
+
+// Done removes the given transfer
+func (self *Progresses) Done(p *Progress) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	delete(self.running, p.Id)
+}
+
+// List returns a copy of all running transfers - oldest first
+func (self *Progresses) List() []Progress {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	ps := make([]Progress, 0, len(self.running))
+	for _, p := range self.running {
+		ps = append(ps, Progress{
+			Id:            p.Id,
+			Name:          p.Name,
+			Started:       p.Started,
+			Transferred:   atomic.LoadUint64(&p.Transferred),
+			EstimatedSize: p.EstimatedSize,
+		})
+	}
+	sort.Slice(ps, func(i, j int) bool { return ps[i].Id < ps[j].Id })
+	return ps
+}
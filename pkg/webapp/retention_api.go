@@ -0,0 +1,105 @@
+package webapp
+
+import (
+	"fmt"
+	"github.com/j-keck/zfs-snap-diff/pkg/config"
+	"github.com/j-keck/zfs-snap-diff/pkg/retention"
+	"net/http"
+	"strings"
+)
+
+/// responds with the retention plans for the given dataset
+///
+/// expected payload: { datasetName: "name"
+///                   [, policy: { namePattern: "auto-*", hourly: 24, daily: 14, weekly: 8, monthly: 12, yearly: 0 } ]
+///                   }
+///
+/// without a 'policy' in the payload, the configured policies for the dataset are used.
+func (self *WebApp) retentionPlanHndl(w http.ResponseWriter, r *http.Request) {
+	self.retentionHndl(w, r, false)
+}
+
+/// destroys the expired snapshots per retention policy
+///
+/// expected payload: see 'retentionPlanHndl'
+func (self *WebApp) applyRetentionHndl(w http.ResponseWriter, r *http.Request) {
+	self.retentionHndl(w, r, true)
+}
+
+func (self *WebApp) retentionHndl(w http.ResponseWriter, r *http.Request, apply bool) {
+	// decode the payload
+	type Payload struct {
+		DatasetName string                  `json:"datasetName"`
+		Policy      *config.RetentionPolicy `json:"policy"`
+	}
+
+	payload, ok := decodeJsonPayload(w, r, &Payload{}).(*Payload)
+	if !ok {
+		return
+	}
+
+	// get the dataset
+	ds, err := self.zfs.FindDatasetByName(payload.DatasetName)
+	if err != nil {
+		msg := fmt.Sprintf("Dataset with name: %s not found - %v", payload.DatasetName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 400)
+		return
+	}
+
+	if apply && !self.checkPermitted(w, r, ds, "destroy") {
+		return
+	}
+
+	var policies []config.RetentionPolicy
+	if payload.Policy != nil {
+		payload.Policy.DatasetName = ds.Name
+		policies = append(policies, *payload.Policy)
+	} else {
+		policies = retention.PoliciesForDataset(ds.Name)
+	}
+
+	if len(policies) == 0 {
+		msg := fmt.Sprintf("No retention policy for dataset: %s", ds.Name)
+		log.Error(msg)
+		respondError(w, msg, nil, 400)
+		return
+	}
+
+	plans := []retention.Plan{}
+	for _, policy := range policies {
+		plan, err := retention.PlanForDataset(r.Context(), ds, policy)
+		if err != nil {
+			msg := fmt.Sprintf("Unable to compute the retention plan for dataset: %s - %v", ds.Name, err)
+			log.Error(msg)
+			respondError(w, msg, err, 400)
+			return
+		}
+		plans = append(plans, plan)
+	}
+
+	if !apply {
+		respond(w, r, plans)
+		return
+	}
+
+	var destroyed, failed []string
+	for _, plan := range plans {
+		names, err := plan.Apply(r.Context(), ds)
+		destroyed = append(destroyed, names...)
+		if err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+
+	if len(failed) > 0 {
+		msg := fmt.Sprintf("%d snapshot(s) destroyed - %s", len(destroyed), strings.Join(failed, ", "))
+		log.Error(msg)
+		respondError(w, msg, nil, 500)
+		return
+	}
+
+	msg := fmt.Sprintf("%d snapshot(s) destroyed", len(destroyed))
+	log.Info(msg)
+	w.Write([]byte(msg))
+}
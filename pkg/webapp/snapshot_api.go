@@ -2,7 +2,19 @@ package webapp
 
 import (
 	"fmt"
+	"github.com/j-keck/zfs-snap-diff/pkg/config"
+	"github.com/j-keck/zfs-snap-diff/pkg/fs"
+	"github.com/j-keck/zfs-snap-diff/pkg/scanner"
+	"github.com/j-keck/zfs-snap-diff/pkg/scheduler"
+	"github.com/j-keck/zfs-snap-diff/pkg/zfs"
+	"io"
+	"mime"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 /// responds with a list of snapshots for the given dataset
@@ -12,6 +24,7 @@ func (self *WebApp) snapshotsForDatasetHndl(w http.ResponseWriter, r *http.Reque
 	// decode the payload
 	type Payload struct {
 		DatasetName string `json:"datasetName"`
+		Query       string `json:"query"`
 	}
 
 	payload, ok := decodeJsonPayload(w, r, &Payload{}).(*Payload)
@@ -24,27 +37,109 @@ func (self *WebApp) snapshotsForDatasetHndl(w http.ResponseWriter, r *http.Reque
 	if err != nil {
 		msg := fmt.Sprintf("Dataset with name: %s not found - %v", payload.DatasetName, err)
 		log.Error(msg)
-		http.Error(w, msg, 400)
+		respondError(w, msg, err, 400)
+		return
+	}
+
+	query, err := zfs.ParseSnapshotQuery(payload.Query)
+	if err != nil {
+		msg := fmt.Sprintf("Invalid snapshot query: %s - %v", payload.Query, err)
+		log.Error(msg)
+		respondError(w, msg, err, 400)
 		return
 	}
 
 	// snapshots
-	snaps, err := ds.ScanSnapshots()
+	snaps, err := ds.QuerySnapshots(r.Context(), query)
 	if err != nil {
 		msg := fmt.Sprintf("Unable to scan snapshots for Dataset: %s - %v", payload.DatasetName, err)
 		log.Error(msg)
-		http.Error(w, msg, 400)
+		respondError(w, msg, err, 400)
 		return
 	}
 
 	respond(w, r, snaps)
 }
 
-func (self *WebApp) createSnapshotHndl(w http.ResponseWriter, r *http.Request) {
+/// responds with a list of bookmarks for the given dataset
+///
+/// expected payload: { datasetName: "name" }
+func (self *WebApp) bookmarksForDatasetHndl(w http.ResponseWriter, r *http.Request) {
+	// decode the payload
+	type Payload struct {
+		DatasetName string `json:"datasetName"`
+	}
+
+	payload, ok := decodeJsonPayload(w, r, &Payload{}).(*Payload)
+	if !ok {
+		return
+	}
+
+	// get the dataset
+	ds, err := self.zfs.FindDatasetByName(payload.DatasetName)
+	if err != nil {
+		msg := fmt.Sprintf("Dataset with name: %s not found - %v", payload.DatasetName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 400)
+		return
+	}
+
+	// bookmarks
+	bookmarks, err := ds.ScanBookmarks(r.Context())
+	if err != nil {
+		msg := fmt.Sprintf("Unable to scan bookmarks for Dataset: %s - %v", payload.DatasetName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 400)
+		return
+	}
+
+	respond(w, r, bookmarks)
+}
+
+func (self *WebApp) createBookmarkHndl(w http.ResponseWriter, r *http.Request) {
 	// decode the payload
 	type Payload struct {
 		DatasetName  string `json:"datasetName"`
 		SnapshotName string `json:"snapshotName"`
+		BookmarkName string `json:"bookmarkName"`
+	}
+
+	payload, ok := decodeJsonPayload(w, r, &Payload{}).(*Payload)
+	if !ok {
+		return
+	}
+
+	// get the dataset
+	ds, err := self.zfs.FindDatasetByName(payload.DatasetName)
+	if err != nil {
+		msg := fmt.Sprintf("Dataset with name: %s not found - %v", payload.DatasetName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 400)
+		return
+	}
+
+	if !self.checkPermitted(w, r, ds, "bookmark") {
+		return
+	}
+
+	name, err := ds.CreateBookmark(r.Context(), payload.SnapshotName, payload.BookmarkName)
+	if err != nil {
+		msg := fmt.Sprintf("Unable to create bookmark: %s - %v", name, err)
+		log.Error(msg)
+		respondError(w, msg, err, 500)
+		return
+	}
+
+	msg := fmt.Sprintf("Bookmark '%s' created", name)
+	log.Info(msg)
+	w.Write([]byte(msg))
+}
+
+func (self *WebApp) destroyBookmarkHndl(w http.ResponseWriter, r *http.Request) {
+	// decode the payload
+	type Payload struct {
+		DatasetName  string `json:"datasetName"`
+		BookmarkName string `json:"bookmarkName"`
 	}
 
 	payload, ok := decodeJsonPayload(w, r, &Payload{}).(*Payload)
@@ -57,19 +152,124 @@ func (self *WebApp) createSnapshotHndl(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		msg := fmt.Sprintf("Dataset with name: %s not found - %v", payload.DatasetName, err)
 		log.Error(msg)
-		http.Error(w, msg, 400)
+		respondError(w, msg, err, 400)
 		return
 	}
 
-	name, err := ds.CreateSnapshot(payload.SnapshotName)
+	if !self.checkPermitted(w, r, ds, "destroy") {
+		return
+	}
+
+	err = ds.DestroyBookmark(r.Context(), payload.BookmarkName)
+	if err != nil {
+		msg := fmt.Sprintf("Unable to destroy bookmark: %s - %v", payload.BookmarkName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 500)
+		return
+	}
+
+	msg := fmt.Sprintf("Bookmark '%s' destroyed", payload.BookmarkName)
+	log.Info(msg)
+	w.Write([]byte(msg))
+}
+
+/// creates a snapshot
+///
+/// expected payload: { datasetName: "name"
+///                   , snapshotName: "snap"
+///                   [, snapshotFlags: ["-r"] ]
+///                   [, datasetNames: ["other-dataset", ...] ]
+///                   [, properties: { "com.example:prop": "value" } ]
+///                   }
+///
+/// with 'datasetNames', the snapshot is created atomically across all given datasets.
+/// an empty 'snapshotName' creates the name from the configured 'snapshot-name-template'.
+func (self *WebApp) createSnapshotHndl(w http.ResponseWriter, r *http.Request) {
+	// decode the payload
+	type Payload struct {
+		DatasetName   string            `json:"datasetName"`
+		SnapshotName  string            `json:"snapshotName"`
+		SnapshotFlags []string          `json:"snapshotFlags"`
+		DatasetNames  []string          `json:"datasetNames"`
+		Properties    map[string]string `json:"properties"`
+	}
+
+	payload, ok := decodeJsonPayload(w, r, &Payload{}).(*Payload)
+	if !ok {
+		return
+	}
+
+	// get the dataset
+	ds, err := self.zfs.FindDatasetByName(payload.DatasetName)
+	if err != nil {
+		msg := fmt.Sprintf("Dataset with name: %s not found - %v", payload.DatasetName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 400)
+		return
+	}
+
+	if !self.checkPermitted(w, r, ds, "snapshot") {
+		return
+	}
+
+	if len(payload.SnapshotName) == 0 {
+		payload.SnapshotName, err = ds.SnapshotNameFromTemplate(config.Get.SnapshotNameTemplate, time.Now())
+		if err != nil {
+			msg := fmt.Sprintf("Unable to create the snapshot name from template: %s - %v",
+				config.Get.SnapshotNameTemplate, err)
+			log.Error(msg)
+			respondError(w, msg, err, 400)
+			return
+		}
+	}
+
+	var flags []string
+	for _, flag := range payload.SnapshotFlags {
+		if is_valid_flag([]string{"-r"}, flag) {
+			flags = append(flags, flag)
+		} else {
+			log.Warnf("ignore invalid create snapshot flag: '%s'", flag)
+		}
+	}
+
+	var names []string
+	if len(payload.DatasetNames) > 0 {
+		datasetNames := []string{ds.Name}
+		for _, name := range payload.DatasetNames {
+			if name == ds.Name {
+				continue
+			}
+
+			other, err := self.zfs.FindDatasetByName(name)
+			if err != nil {
+				msg := fmt.Sprintf("Dataset with name: %s not found - %v", name, err)
+				log.Error(msg)
+				respondError(w, msg, err, 400)
+				return
+			}
+
+			if !self.checkPermitted(w, r, other, "snapshot") {
+				return
+			}
+
+			datasetNames = append(datasetNames, name)
+		}
+
+		names, err = self.zfs.CreateSnapshots(r.Context(), datasetNames, payload.SnapshotName, flags, payload.Properties)
+	} else {
+		var name string
+		name, err = ds.CreateSnapshot(r.Context(), payload.SnapshotName, flags, payload.Properties)
+		names = []string{name}
+	}
+
 	if err != nil {
-		msg := fmt.Sprintf("Unable to create snapshot: %s - %v", name, err)
+		msg := fmt.Sprintf("Unable to create snapshot: %s - %v", payload.SnapshotName, err)
 		log.Error(msg)
-		http.Error(w, msg, 500)
+		respondError(w, msg, err, 500)
 		return
 	}
 
-	msg := fmt.Sprintf("Snapshot '%s' created", name)
+	msg := fmt.Sprintf("Snapshot '%s' created", strings.Join(names, "', '"))
 	log.Info(msg)
 	w.Write([]byte(msg))
 }
@@ -80,6 +280,7 @@ func (self *WebApp) destroySnapshotHndl(w http.ResponseWriter, r *http.Request)
 		DatasetName  string   `json:"datasetName"`
 		SnapshotName string   `json:"snapshotName"`
 		DestroyFlags []string `json:"destroyFlags"`
+		DryRun       bool     `json:"dryRun"`
 	}
 
 	payload, ok := decodeJsonPayload(w, r, &Payload{}).(*Payload)
@@ -92,7 +293,11 @@ func (self *WebApp) destroySnapshotHndl(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		msg := fmt.Sprintf("Dataset with name: %s not found - %v", payload.DatasetName, err)
 		log.Error(msg)
-		http.Error(w, msg, 400)
+		respondError(w, msg, err, 400)
+		return
+	}
+
+	if !self.checkPermitted(w, r, ds, "destroy") {
 		return
 	}
 
@@ -105,11 +310,24 @@ func (self *WebApp) destroySnapshotHndl(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	err = ds.DestroySnapshot(payload.SnapshotName, flags)
+	// only show what would be destroyed
+	if payload.DryRun {
+		preview, err := ds.PreviewDestroySnapshot(r.Context(), payload.SnapshotName, flags)
+		if err != nil {
+			msg := fmt.Sprintf("Unable to preview destroy of snapshot: %s - %v", payload.SnapshotName, err)
+			log.Error(msg)
+			respondError(w, msg, err, 500)
+			return
+		}
+		respond(w, r, preview)
+		return
+	}
+
+	err = ds.DestroySnapshot(r.Context(), payload.SnapshotName, flags)
 	if err != nil {
 		msg := fmt.Sprintf("Unable to destroy snapshot: %s - %v", payload.SnapshotName, err)
 		log.Error(msg)
-		http.Error(w, msg, 500)
+		respondError(w, msg, err, 500)
 		return
 	}
 
@@ -124,6 +342,7 @@ func (self *WebApp) rollbackSnapshotHndl(w http.ResponseWriter, r *http.Request)
 		DatasetName   string   `json:"datasetName"`
 		SnapshotName  string   `json:"snapshotName"`
 		RollbackFlags []string `json:"rollbackFlags"`
+		DryRun        bool     `json:"dryRun"`
 	}
 
 	payload, ok := decodeJsonPayload(w, r, &Payload{}).(*Payload)
@@ -136,7 +355,11 @@ func (self *WebApp) rollbackSnapshotHndl(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		msg := fmt.Sprintf("Dataset with name: %s not found - %v", payload.DatasetName, err)
 		log.Error(msg)
-		http.Error(w, msg, 400)
+		respondError(w, msg, err, 400)
+		return
+	}
+
+	if !self.checkPermitted(w, r, ds, "rollback") {
 		return
 	}
 
@@ -149,11 +372,24 @@ func (self *WebApp) rollbackSnapshotHndl(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	err = ds.RollbackSnapshot(payload.SnapshotName, flags)
+	// only show what would be destroyed
+	if payload.DryRun {
+		preview, err := ds.PreviewRollbackSnapshot(r.Context(), payload.SnapshotName, flags)
+		if err != nil {
+			msg := fmt.Sprintf("Unable to preview rollback to snapshot: %s - %v", payload.SnapshotName, err)
+			log.Error(msg)
+			respondError(w, msg, err, 500)
+			return
+		}
+		respond(w, r, preview)
+		return
+	}
+
+	err = ds.RollbackSnapshot(r.Context(), payload.SnapshotName, flags)
 	if err != nil {
 		msg := fmt.Sprintf("Unable to rollback snapshot: %s - %v", payload.SnapshotName, err)
 		log.Error(msg)
-		http.Error(w, msg, 500)
+		respondError(w, msg, err, 500)
 		return
 	}
 
@@ -180,15 +416,19 @@ func (self *WebApp) renameSnapshotHndl(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		msg := fmt.Sprintf("Dataset with name: %s not found - %v", payload.DatasetName, err)
 		log.Error(msg)
-		http.Error(w, msg, 400)
+		respondError(w, msg, err, 400)
 		return
 	}
 
-	err = ds.RenameSnapshot(payload.OldSnapshotName, payload.NewSnapshotName)
+	if !self.checkPermitted(w, r, ds, "rename") {
+		return
+	}
+
+	err = ds.RenameSnapshot(r.Context(), payload.OldSnapshotName, payload.NewSnapshotName)
 	if err != nil {
 		msg := fmt.Sprintf("Unable to rename snapshot: %s - %v", payload.OldSnapshotName, err)
 		log.Error(msg)
-		http.Error(w, msg, 500)
+		respondError(w, msg, err, 500)
 		return
 	}
 
@@ -216,7 +456,11 @@ func (self *WebApp) cloneSnapshotHndl(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		msg := fmt.Sprintf("Dataset with name: %s not found - %v", payload.DatasetName, err)
 		log.Error(msg)
-		http.Error(w, msg, 400)
+		respondError(w, msg, err, 400)
+		return
+	}
+
+	if !self.checkPermitted(w, r, ds, "clone") {
 		return
 	}
 
@@ -229,11 +473,11 @@ func (self *WebApp) cloneSnapshotHndl(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	err = ds.CloneSnapshot(payload.SnapshotName, payload.FsName, flags)
+	err = ds.CloneSnapshot(r.Context(), payload.SnapshotName, payload.FsName, flags)
 	if err != nil {
 		msg := fmt.Sprintf("Unable to clone snapshot: %s - %v", payload.SnapshotName, err)
 		log.Error(msg)
-		http.Error(w, msg, 500)
+		respondError(w, msg, err, 500)
 		return
 	}
 
@@ -242,6 +486,558 @@ func (self *WebApp) cloneSnapshotHndl(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(msg))
 }
 
+/// responds with the clones from the snapshots of the given dataset
+///
+/// expected payload: { datasetName: "name" }
+func (self *WebApp) clonesForDatasetHndl(w http.ResponseWriter, r *http.Request) {
+	// decode the payload
+	type Payload struct {
+		DatasetName string `json:"datasetName"`
+	}
+
+	payload, ok := decodeJsonPayload(w, r, &Payload{}).(*Payload)
+	if !ok {
+		return
+	}
+
+	// get the dataset
+	ds, err := self.zfs.FindDatasetByName(payload.DatasetName)
+	if err != nil {
+		msg := fmt.Sprintf("Dataset with name: %s not found - %v", payload.DatasetName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 400)
+		return
+	}
+
+	clones, err := ds.ListClones(r.Context())
+	if err != nil {
+		msg := fmt.Sprintf("Unable to list clones for dataset: %s - %v", payload.DatasetName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 500)
+		return
+	}
+
+	respond(w, r, clones)
+}
+
+/// promotes a clone from a snapshot of the given dataset
+///
+/// expected payload: { datasetName: "name", cloneName: "clone" }
+func (self *WebApp) promoteCloneHndl(w http.ResponseWriter, r *http.Request) {
+	// decode the payload
+	type Payload struct {
+		DatasetName string `json:"datasetName"`
+		CloneName   string `json:"cloneName"`
+	}
+
+	payload, ok := decodeJsonPayload(w, r, &Payload{}).(*Payload)
+	if !ok {
+		return
+	}
+
+	// get the dataset
+	ds, err := self.zfs.FindDatasetByName(payload.DatasetName)
+	if err != nil {
+		msg := fmt.Sprintf("Dataset with name: %s not found - %v", payload.DatasetName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 400)
+		return
+	}
+
+	// the permissions are checked on the clone - not on the origin
+	clone, err := ds.LookupClone(r.Context(), payload.CloneName)
+	if err != nil {
+		msg := fmt.Sprintf("Clone with name: %s not found - %v", payload.CloneName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 400)
+		return
+	}
+
+	if !self.checkPermitted(w, r, clone, "promote") {
+		return
+	}
+
+	err = ds.PromoteClone(r.Context(), payload.CloneName)
+	if err != nil {
+		msg := fmt.Sprintf("Unable to promote clone: %s - %v", payload.CloneName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 500)
+		return
+	}
+
+	// the origins have changed
+	if err := self.zfs.RescanDatasets(r.Context()); err != nil {
+		log.Warnf("unable to rescan datasets - %v", err)
+	}
+
+	msg := fmt.Sprintf("Clone '%s' promoted", payload.CloneName)
+	log.Info(msg)
+	w.Write([]byte(msg))
+}
+
+/// destroys a clone from a snapshot of the given dataset
+///
+/// expected payload: { datasetName: "name", cloneName: "clone", destroyFlags: ["-r"] }
+func (self *WebApp) destroyCloneHndl(w http.ResponseWriter, r *http.Request) {
+	// decode the payload
+	type Payload struct {
+		DatasetName  string   `json:"datasetName"`
+		CloneName    string   `json:"cloneName"`
+		DestroyFlags []string `json:"destroyFlags"`
+	}
+
+	payload, ok := decodeJsonPayload(w, r, &Payload{}).(*Payload)
+	if !ok {
+		return
+	}
+
+	// get the dataset
+	ds, err := self.zfs.FindDatasetByName(payload.DatasetName)
+	if err != nil {
+		msg := fmt.Sprintf("Dataset with name: %s not found - %v", payload.DatasetName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 400)
+		return
+	}
+
+	// the permissions are checked on the clone - not on the origin
+	clone, err := ds.LookupClone(r.Context(), payload.CloneName)
+	if err != nil {
+		msg := fmt.Sprintf("Clone with name: %s not found - %v", payload.CloneName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 400)
+		return
+	}
+
+	if !self.checkPermitted(w, r, clone, "destroy") {
+		return
+	}
+
+	var flags []string
+	for _, flag := range payload.DestroyFlags {
+		if is_valid_flag([]string{"-R", "-f", "-r"}, flag) {
+			flags = append(flags, flag)
+		} else {
+			log.Warnf("ignore invalid destroy clone flag: '%s'", flag)
+		}
+	}
+
+	err = ds.DestroyClone(r.Context(), payload.CloneName, flags)
+	if err != nil {
+		msg := fmt.Sprintf("Unable to destroy clone: %s - %v", payload.CloneName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 500)
+		return
+	}
+
+	// the clone can be one of the managed datasets
+	if err := self.zfs.RescanDatasets(r.Context()); err != nil {
+		log.Warnf("unable to rescan datasets - %v", err)
+	}
+
+	msg := fmt.Sprintf("Clone '%s' destroyed", payload.CloneName)
+	log.Info(msg)
+	w.Write([]byte(msg))
+}
+
+/// responds with the snapshot schedules incl. the last and next run
+func (self *WebApp) snapshotSchedulesHndl(w http.ResponseWriter, r *http.Request) {
+	jobs := []scheduler.Job{}
+	if self.scheduler != nil {
+		jobs = self.scheduler.Jobs()
+	}
+	respond(w, r, jobs)
+}
+
+/// responds with the holds from the given snapshot
+///
+/// expected payload: { datasetName: "name", snapshotName: "snap" }
+func (self *WebApp) holdsForSnapshotHndl(w http.ResponseWriter, r *http.Request) {
+	// decode the payload
+	type Payload struct {
+		DatasetName  string `json:"datasetName"`
+		SnapshotName string `json:"snapshotName"`
+	}
+
+	payload, ok := decodeJsonPayload(w, r, &Payload{}).(*Payload)
+	if !ok {
+		return
+	}
+
+	// get the dataset
+	ds, err := self.zfs.FindDatasetByName(payload.DatasetName)
+	if err != nil {
+		msg := fmt.Sprintf("Dataset with name: %s not found - %v", payload.DatasetName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 400)
+		return
+	}
+
+	holds, err := ds.ListHolds(r.Context(), payload.SnapshotName)
+	if err != nil {
+		msg := fmt.Sprintf("Unable to list holds for snapshot: %s - %v", payload.SnapshotName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 500)
+		return
+	}
+
+	respond(w, r, holds)
+}
+
+func (self *WebApp) holdSnapshotHndl(w http.ResponseWriter, r *http.Request) {
+	// decode the payload
+	type Payload struct {
+		DatasetName  string   `json:"datasetName"`
+		SnapshotName string   `json:"snapshotName"`
+		Tag          string   `json:"tag"`
+		HoldFlags    []string `json:"holdFlags"`
+	}
+
+	payload, ok := decodeJsonPayload(w, r, &Payload{}).(*Payload)
+	if !ok {
+		return
+	}
+
+	// get the dataset
+	ds, err := self.zfs.FindDatasetByName(payload.DatasetName)
+	if err != nil {
+		msg := fmt.Sprintf("Dataset with name: %s not found - %v", payload.DatasetName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 400)
+		return
+	}
+
+	if !self.checkPermitted(w, r, ds, "hold") {
+		return
+	}
+
+	var flags []string
+	for _, flag := range payload.HoldFlags {
+		if is_valid_flag([]string{"-r"}, flag) {
+			flags = append(flags, flag)
+		} else {
+			log.Warnf("ignore invalid hold snapshot flag: '%s'", flag)
+		}
+	}
+
+	err = ds.HoldSnapshot(r.Context(), payload.SnapshotName, payload.Tag, flags)
+	if err != nil {
+		msg := fmt.Sprintf("Unable to hold snapshot: %s - %v", payload.SnapshotName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 500)
+		return
+	}
+
+	msg := fmt.Sprintf("Hold '%s' placed on snapshot '%s'", payload.Tag, payload.SnapshotName)
+	log.Info(msg)
+	w.Write([]byte(msg))
+}
+
+func (self *WebApp) releaseSnapshotHndl(w http.ResponseWriter, r *http.Request) {
+	// decode the payload
+	type Payload struct {
+		DatasetName  string   `json:"datasetName"`
+		SnapshotName string   `json:"snapshotName"`
+		Tag          string   `json:"tag"`
+		ReleaseFlags []string `json:"releaseFlags"`
+	}
+
+	payload, ok := decodeJsonPayload(w, r, &Payload{}).(*Payload)
+	if !ok {
+		return
+	}
+
+	// get the dataset
+	ds, err := self.zfs.FindDatasetByName(payload.DatasetName)
+	if err != nil {
+		msg := fmt.Sprintf("Dataset with name: %s not found - %v", payload.DatasetName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 400)
+		return
+	}
+
+	var flags []string
+	if !self.checkPermitted(w, r, ds, "release") {
+		return
+	}
+
+	for _, flag := range payload.ReleaseFlags {
+		if is_valid_flag([]string{"-r"}, flag) {
+			flags = append(flags, flag)
+		} else {
+			log.Warnf("ignore invalid release snapshot flag: '%s'", flag)
+		}
+	}
+
+	err = ds.ReleaseSnapshot(r.Context(), payload.SnapshotName, payload.Tag, flags)
+	if err != nil {
+		msg := fmt.Sprintf("Unable to release snapshot: %s - %v", payload.SnapshotName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 500)
+		return
+	}
+
+	msg := fmt.Sprintf("Hold '%s' released from snapshot '%s'", payload.Tag, payload.SnapshotName)
+	log.Info(msg)
+	w.Write([]byte(msg))
+}
+
+/// responds with the changes between two snapshots
+///
+/// expected payload: { datasetName: "name"
+///                   , fromSnapshotName: "snap1"
+///                   [, toSnapshotName: "snap2" ]
+///                   [, compareMethod: [auto|size|mtime|size+mtime|content|md5] ]
+///                   }
+///
+/// if 'toSnapshotName' is missing, the snapshot is compared against the current filesystem state.
+/// if 'zfs diff' is not permitted, the snapshot directories are compared per 'compareMethod'.
+func (self *WebApp) snapshotDiffHndl(w http.ResponseWriter, r *http.Request) {
+	// decode the payload
+	type Payload struct {
+		DatasetName      string `json:"datasetName"`
+		FromSnapshotName string `json:"fromSnapshotName"`
+		ToSnapshotName   string `json:"toSnapshotName"`
+		CompareMethod    string `json:"compareMethod"`
+	}
+
+	defaults := Payload{CompareMethod: config.Get.CompareMethod}
+	payload, ok := decodeJsonPayload(w, r, &defaults).(*Payload)
+	if !ok {
+		return
+	}
+
+	// get the dataset
+	ds, err := self.zfs.FindDatasetByName(payload.DatasetName)
+	if err != nil {
+		msg := fmt.Sprintf("Dataset with name: %s not found - %v", payload.DatasetName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 400)
+		return
+	}
+
+	sc := scanner.NewScanner(scanner.DateRange{}, payload.CompareMethod, ds, self.zfs, zfs.SnapshotQuery{})
+	var changes zfs.FileChanges
+	if perr := self.permitted(r.Context(), ds, "diff"); perr != nil {
+		log.Debugf("walk the snapshot directories - %v", perr)
+		changes, err = sc.WalkDiffSnapshots(r.Context(), payload.FromSnapshotName, payload.ToSnapshotName)
+	} else {
+		changes, err = sc.DiffSnapshots(r.Context(), payload.FromSnapshotName, payload.ToSnapshotName)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Unable to diff snapshot: %s - %v", payload.FromSnapshotName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 500)
+		return
+	}
+
+	respond(w, r, changes)
+}
+
+/// streams a snapshot per 'zfs send' as a download
+///
+/// expected request parameters: /api/send-snapshot?dataset-name=name&snapshot-name=snap
+///                                 [&incremental-from=other-snap][&flag=-w][&flag=-I]...
+///
+/// the response header 'X-Estimated-Size' contains the estimated stream size.
+/// the progress from running downloads can be requested per '/api/send-snapshot-progress'.
+func (self *WebApp) sendSnapshotHndl(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	datasetName := query.Get("dataset-name")
+	snapshotName := query.Get("snapshot-name")
+	incrementalFrom := query.Get("incremental-from")
+
+	// validate the parameters
+	if len(snapshotName) == 0 {
+		msg := "Unable to send snapshot - Paramater 'snapshot-name' missing"
+		log.Error(msg)
+		respondError(w, msg, nil, 400)
+		return
+	}
+
+	// get the dataset
+	ds, err := self.zfs.FindDatasetByName(datasetName)
+	if err != nil {
+		msg := fmt.Sprintf("Dataset with name: %s not found - %v", datasetName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 400)
+		return
+	}
+
+	if !self.checkPermitted(w, r, ds, "send") {
+		return
+	}
+
+	var flags []string
+	for _, flag := range query["flag"] {
+		if is_valid_flag([]string{"-I", "-L", "-c", "-e", "-p", "-w"}, flag) {
+			flags = append(flags, flag)
+		} else {
+			log.Warnf("ignore invalid send snapshot flag: '%s'", flag)
+		}
+	}
+
+	// the dry-run validates the parameters before the download starts
+	estimatedSize, err := ds.EstimateSendSize(r.Context(), snapshotName, incrementalFrom, flags)
+	if err != nil {
+		msg := fmt.Sprintf("Unable to send snapshot: %s - %v", snapshotName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 500)
+		return
+	}
+
+	name := strings.Replace(ds.Name+"@"+snapshotName, "/", "_", -1)
+	if len(incrementalFrom) > 0 {
+		name = name + "_from_" + strings.Replace(incrementalFrom, "/", "_", -1)
+	}
+	name = name + ".zfs"
+
+	log.Infof("send snapshot: %s@%s as: %s (estimated size: %d bytes)", ds.Name, snapshotName, name, estimatedSize)
+	progress := self.sends.Start(name, estimatedSize)
+	defer self.sends.Done(progress)
+
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("X-Estimated-Size", strconv.FormatUint(estimatedSize, 10))
+	if err := ds.SendSnapshot(r.Context(), snapshotName, incrementalFrom, flags, io.MultiWriter(w, progress)); err != nil {
+		// the header is already sent - only log the error
+		log.Errorf("Unable to send snapshot: %s - %v", snapshotName, err)
+		return
+	}
+	log.Infof("snapshot: %s@%s sent - %d bytes", ds.Name, snapshotName, atomic.LoadUint64(&progress.Transferred))
+}
+
+/// responds with the progress from the running snapshot downloads
+func (self *WebApp) sendSnapshotProgressHndl(w http.ResponseWriter, r *http.Request) {
+	respond(w, r, self.sends.List())
+}
+
+/// receives a 'zfs send' stream per 'zfs receive' into the given dataset
+///
+/// the stream can be uploaded per
+///   - request body: /api/receive-snapshot?dataset-name=tank/restored[&flag=-s]...
+///   - multipart form upload (first file) with the same request parameters
+///
+/// or read from a local file per a json payload:
+///
+///   { datasetName: "tank/restored", path: "/path/to/stream" [, receiveFlags: ["-s"] ] }
+///
+/// the target dataset must not exist and must live below the dataset tree from zfs-snap-diff.
+/// the received dataset is not mounted and inherits the mountpoint - '-u' is always set.
+func (self *WebApp) receiveSnapshotHndl(w http.ResponseWriter, r *http.Request) {
+	type Payload struct {
+		DatasetName  string   `json:"datasetName"`
+		Path         string   `json:"path"`
+		ReceiveFlags []string `json:"receiveFlags"`
+	}
+
+	// determine the request type and extract the payload and the stream
+	payload := Payload{}
+	var stream io.Reader
+	contentType := r.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "application/json") {
+		p, ok := decodeJsonPayload(w, r, &Payload{}).(*Payload)
+		if !ok {
+			return
+		}
+		payload = *p
+
+		// validate path
+		if err := self.checkPathIsAllowed(payload.Path); err != nil {
+			respondError(w, err.Error(), err, 400)
+			return
+		}
+
+		fh, err := fs.GetFileHandle(payload.Path)
+		if err != nil {
+			msg := fmt.Sprintf("Unable to open the stream: %s - %v", payload.Path, err)
+			log.Error(msg)
+			respondError(w, msg, err, 400)
+			return
+		}
+
+		f, err := fh.Open()
+		if err != nil {
+			msg := fmt.Sprintf("Unable to open the stream: %s - %v", payload.Path, err)
+			log.Error(msg)
+			respondError(w, msg, err, 500)
+			return
+		}
+		defer f.Close()
+		stream = f
+	} else {
+		payload.DatasetName = r.URL.Query().Get("dataset-name")
+		payload.ReceiveFlags = r.URL.Query()["flag"]
+
+		if strings.HasPrefix(contentType, "multipart/form-data") {
+			mr, err := r.MultipartReader()
+			if err != nil {
+				msg := fmt.Sprintf("Unable to read the upload - %v", err)
+				log.Error(msg)
+				respondError(w, msg, err, 400)
+				return
+			}
+
+			for {
+				part, err := mr.NextPart()
+				if err != nil {
+					msg := fmt.Sprintf("No stream in the upload found - %v", err)
+					log.Error(msg)
+					respondError(w, msg, err, 400)
+					return
+				}
+				if part.FileName() != "" {
+					stream = part
+					break
+				}
+			}
+		} else {
+			stream = r.Body
+		}
+	}
+
+	var flags []string
+	for _, flag := range payload.ReceiveFlags {
+		if is_valid_flag([]string{"-s", "-u"}, flag) {
+			flags = append(flags, flag)
+		} else {
+			log.Warnf("ignore invalid receive snapshot flag: '%s'", flag)
+		}
+	}
+
+	if err := self.zfs.CheckReceiveTarget(r.Context(), payload.DatasetName); err != nil {
+		msg := fmt.Sprintf("Unable to receive stream into: %s - %v", payload.DatasetName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 400)
+		return
+	}
+
+	// the target doesn't exist - check the permissions on the parent dataset
+	parentName := path.Dir(strings.SplitN(payload.DatasetName, "@", 2)[0])
+	if parent, err := self.zfs.FindDatasetByName(parentName); err == nil {
+		if !self.checkPermitted(w, r, parent, "receive") {
+			return
+		}
+	}
+
+	log.Infof("receive stream into: %s - requested from: %s", payload.DatasetName, r.RemoteAddr)
+	if err := self.zfs.ReceiveSnapshot(r.Context(), payload.DatasetName, flags, stream); err != nil {
+		msg := fmt.Sprintf("Unable to receive stream into: %s - %v", payload.DatasetName, err)
+		log.Error(msg)
+		respondError(w, msg, err, 500)
+		return
+	}
+
+	// the received dataset is listed once it gets mounted
+	if err := self.zfs.RescanDatasets(r.Context()); err != nil {
+		log.Warnf("Unable to rescan datasets - %v", err)
+	}
+
+	msg := fmt.Sprintf("Stream received into '%s'", payload.DatasetName)
+	log.Info(msg)
+	w.Write([]byte(msg))
+}
+
 func is_valid_flag(valid []string, flag string) bool {
 	for _, v := range valid {
 		if v == flag {
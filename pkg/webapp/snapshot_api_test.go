@@ -0,0 +1,202 @@
+package webapp
+
+import (
+	"encoding/json"
+	"github.com/j-keck/zfs-snap-diff/pkg/zfs"
+	"github.com/j-keck/zfs-snap-diff/pkg/zfs/zfstest"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func newTestWebApp(t *testing.T) (*WebApp, func()) {
+	root, err := ioutil.TempDir("", "zsd-webapp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := zfstest.NewZFSCmdFake(root)
+	if _, err := fake.CreateDataset("tank/fs1"); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zfs.NewZFSWithCmd(fake, "tank")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &WebApp{zfs: z, sends: NewProgresses()}, func() { os.RemoveAll(root) }
+}
+
+func post(hndl http.HandlerFunc, payload string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "/api", strings.NewReader(payload))
+	w := httptest.NewRecorder()
+	hndl(w, r)
+	return w
+}
+
+func TestSnapshotHandlers(t *testing.T) {
+	app, cleanup := newTestWebApp(t)
+	defer cleanup()
+
+	if w := post(app.createSnapshotHndl, `{"datasetName": "tank/fs1", "snapshotName": "one"}`); w.Code != 200 {
+		t.Fatalf("create snapshot failed: %d - %s", w.Code, w.Body)
+	}
+
+	if w := post(app.renameSnapshotHndl,
+		`{"datasetName": "tank/fs1", "oldSnapshotName": "one", "newSnapshotName": "two"}`); w.Code != 200 {
+		t.Fatalf("rename snapshot failed: %d - %s", w.Code, w.Body)
+	}
+
+	w := post(app.snapshotsForDatasetHndl, `{"datasetName": "tank/fs1"}`)
+	var snaps []zfs.Snapshot
+	if err := json.Unmarshal(w.Body.Bytes(), &snaps); err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 1 || snaps[0].Name != "two" {
+		t.Fatalf("unexpected snapshots: %v", snaps)
+	}
+
+	if w := post(app.holdSnapshotHndl,
+		`{"datasetName": "tank/fs1", "snapshotName": "two", "tag": "keep"}`); w.Code != 200 {
+		t.Fatalf("hold snapshot failed: %d - %s", w.Code, w.Body)
+	}
+
+	w = post(app.destroySnapshotHndl, `{"datasetName": "tank/fs1", "snapshotName": "two"}`)
+	if w.Code != 409 || !strings.Contains(w.Body.String(), "keep") {
+		t.Errorf("destroy a snapshot with a hold: %d - %s", w.Code, w.Body)
+	}
+
+	var errResp ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &errResp); err != nil || errResp.Kind != zfs.ErrorHoldPresent {
+		t.Errorf("unexpected error response: %+v - %v", errResp, err)
+	}
+
+	if w := post(app.releaseSnapshotHndl,
+		`{"datasetName": "tank/fs1", "snapshotName": "two", "tag": "keep"}`); w.Code != 200 {
+		t.Fatalf("release snapshot failed: %d - %s", w.Code, w.Body)
+	}
+
+	if w := post(app.destroySnapshotHndl, `{"datasetName": "tank/fs1", "snapshotName": "two"}`); w.Code != 200 {
+		t.Fatalf("destroy snapshot failed: %d - %s", w.Code, w.Body)
+	}
+
+	if w := post(app.createSnapshotHndl, `{"datasetName": "tank/unknown", "snapshotName": "one"}`); w.Code != 404 ||
+		w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("create snapshot in unknown dataset: %d - %s", w.Code, w.Body)
+	}
+}
+
+func TestDryRunHandlers(t *testing.T) {
+	app, cleanup := newTestWebApp(t)
+	defer cleanup()
+
+	for _, name := range []string{"one", "two"} {
+		payload := `{"datasetName": "tank/fs1", "snapshotName": "` + name + `"}`
+		if w := post(app.createSnapshotHndl, payload); w.Code != 200 {
+			t.Fatalf("create snapshot failed: %d - %s", w.Code, w.Body)
+		}
+	}
+
+	w := post(app.destroySnapshotHndl, `{"datasetName": "tank/fs1", "snapshotName": "two", "dryRun": true}`)
+	var preview zfs.ImpactPreview
+	if err := json.Unmarshal(w.Body.Bytes(), &preview); err != nil {
+		t.Fatalf("%v - %s", err, w.Body)
+	}
+	if len(preview.Destroyed) != 1 || preview.Destroyed[0] != "tank/fs1@two" {
+		t.Errorf("unexpected destroy preview: %+v", preview)
+	}
+
+	w = post(app.rollbackSnapshotHndl,
+		`{"datasetName": "tank/fs1", "snapshotName": "one", "rollbackFlags": ["-r"], "dryRun": true}`)
+	if err := json.Unmarshal(w.Body.Bytes(), &preview); err != nil {
+		t.Fatalf("%v - %s", err, w.Body)
+	}
+	if len(preview.Destroyed) != 1 || preview.Destroyed[0] != "tank/fs1@two" {
+		t.Errorf("unexpected rollback preview: %+v", preview)
+	}
+
+	// nothing destroyed
+	w = post(app.snapshotsForDatasetHndl, `{"datasetName": "tank/fs1"}`)
+	var snaps []zfs.Snapshot
+	if err := json.Unmarshal(w.Body.Bytes(), &snaps); err != nil || len(snaps) != 2 {
+		t.Errorf("unexpected snapshots after dry-run: %v - %v", snaps, err)
+	}
+}
+
+func TestCloneHandlers(t *testing.T) {
+	app, cleanup := newTestWebApp(t)
+	defer cleanup()
+
+	if w := post(app.createSnapshotHndl, `{"datasetName": "tank/fs1", "snapshotName": "one"}`); w.Code != 200 {
+		t.Fatalf("create snapshot failed: %d - %s", w.Code, w.Body)
+	}
+	if w := post(app.cloneSnapshotHndl,
+		`{"datasetName": "tank/fs1", "snapshotName": "one", "fsName": "tank/restore"}`); w.Code != 200 {
+		t.Fatalf("clone snapshot failed: %d - %s", w.Code, w.Body)
+	}
+
+	w := post(app.clonesForDatasetHndl, `{"datasetName": "tank/fs1"}`)
+	var clones zfs.Clones
+	if err := json.Unmarshal(w.Body.Bytes(), &clones); err != nil {
+		t.Fatalf("%v - %s", err, w.Body)
+	}
+	if len(clones) != 1 || clones[0].Name != "tank/restore" || clones[0].Origin != "tank/fs1@one" {
+		t.Fatalf("unexpected clones: %v", clones)
+	}
+
+	if w := post(app.destroyCloneHndl, `{"datasetName": "tank/fs1", "cloneName": "tank"}`); w.Code != 404 {
+		t.Errorf("destroy a dataset which is not a clone: %d - %s", w.Code, w.Body)
+	}
+
+	if w := post(app.destroyCloneHndl, `{"datasetName": "tank/fs1", "cloneName": "tank/restore"}`); w.Code != 200 {
+		t.Fatalf("destroy clone failed: %d - %s", w.Code, w.Body)
+	}
+
+	// the snapshot can be pruned now
+	if w := post(app.destroySnapshotHndl, `{"datasetName": "tank/fs1", "snapshotName": "one"}`); w.Code != 200 {
+		t.Errorf("destroy snapshot failed: %d - %s", w.Code, w.Body)
+	}
+}
+
+func TestSnapshotQueryHandler(t *testing.T) {
+	app, cleanup := newTestWebApp(t)
+	defer cleanup()
+
+	for _, name := range []string{"daily-1", "hourly-1"} {
+		if w := post(app.createSnapshotHndl,
+			`{"datasetName": "tank/fs1", "snapshotName": "`+name+`"}`); w.Code != 200 {
+			t.Fatalf("create snapshot failed: %d - %s", w.Code, w.Body)
+		}
+	}
+
+	w := post(app.snapshotsForDatasetHndl, `{"datasetName": "tank/fs1", "query": "name=daily-*"}`)
+	var snaps []zfs.Snapshot
+	if err := json.Unmarshal(w.Body.Bytes(), &snaps); err != nil {
+		t.Fatalf("%v - %s", err, w.Body)
+	}
+	if len(snaps) != 1 || snaps[0].Name != "daily-1" {
+		t.Fatalf("unexpected snapshots: %v", snaps)
+	}
+
+	if w := post(app.snapshotsForDatasetHndl, `{"datasetName": "tank/fs1", "query": "size>1"}`); w.Code != 400 {
+		t.Errorf("invalid query: %d - %s", w.Code, w.Body)
+	}
+}
+
+func TestReceiveSnapshotHandler(t *testing.T) {
+	app, cleanup := newTestWebApp(t)
+	defer cleanup()
+
+	for _, target := range []string{"tank", "tank/fs1", "other/fs1"} {
+		r := httptest.NewRequest("POST", "/api/receive-snapshot?dataset-name="+target, strings.NewReader("stream"))
+		w := httptest.NewRecorder()
+		app.receiveSnapshotHndl(w, r)
+		if w.Code != 400 {
+			t.Errorf("receive into: '%s' - %d - %s", target, w.Code, w.Body)
+		}
+	}
+}
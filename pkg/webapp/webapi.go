@@ -6,6 +6,7 @@ import (
 	"github.com/j-keck/zfs-snap-diff/pkg/diff"
 	"github.com/j-keck/zfs-snap-diff/pkg/fs"
 	"github.com/j-keck/zfs-snap-diff/pkg/scanner"
+	"github.com/j-keck/zfs-snap-diff/pkg/zfs"
 	"net/http"
 	"path/filepath"
 	"strconv"
@@ -25,14 +26,21 @@ func (self *WebApp) configHndl(w http.ResponseWriter, r *http.Request) {
 
 /// re-scan datasets
 func (self *WebApp) rescanDatasetsHndl(w http.ResponseWriter, r *http.Request) {
-	err := self.zfs.RescanDatasets()
+	err := self.zfs.RescanDatasets(r.Context())
 	if err != nil {
 		msg := fmt.Sprintf("Unable to scan datasets: %v", err)
 		log.Error(msg)
-		http.Error(w, msg, 400)
+		respondError(w, msg, err, 400)
 		return
 	}
 
+	// locked datasets are reported with 'locked: true'
+	for _, ds := range self.zfs.Datasets() {
+		if ds.Locked {
+			log.Infof("dataset: %s is locked - the encryption key is not loaded", ds.Name)
+		}
+	}
+
 	respond(w, r, self.zfs.Datasets())
 }
 
@@ -50,7 +58,7 @@ func (self *WebApp) statHndl(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		msg := fmt.Sprintf("Unable to stat path: %s - %v", payload.Path, err)
 		log.Error(msg)
-		http.Error(w, msg, 400)
+		respondError(w, msg, err, 400)
 		return
 	}
 	respond(w, r, fh)
@@ -71,7 +79,7 @@ func (self *WebApp) dirListingHndl(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := self.checkPathIsAllowed(payload.Path); err != nil {
-		http.Error(w, err.Error(), 400)
+		respondError(w, err.Error(), err, 400)
 		return
 	}
 
@@ -80,7 +88,7 @@ func (self *WebApp) dirListingHndl(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		msg := fmt.Sprintf("Unable to get directory handle for: %s - %v", payload.Path, err)
 		log.Error(msg)
-		http.Error(w, msg, 400)
+		respondError(w, msg, err, 400)
 		return
 	}
 
@@ -89,7 +97,7 @@ func (self *WebApp) dirListingHndl(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		msg := fmt.Sprintf("Directory listing failed for directory: %s - %v", payload.Path, err)
 		log.Error(msg)
-		http.Error(w, msg, 500)
+		respondError(w, msg, err, 500)
 		return
 	}
 
@@ -101,6 +109,7 @@ func (self *WebApp) dirListingHndl(w http.ResponseWriter, r *http.Request) {
 /// expected payload: { path: "/path/to/file"
 ///                     [, compareMethod: [auto|size|mtime|size+mtime|content|md5] ]
 ///                     [, dateRange: {from: "2019-01-01", to: "2019-02-01"} ]
+///                     [, query: "name=daily-*" ]
 ///                   }
 ///
 func (self *WebApp) findFileVersionsHndl(w http.ResponseWriter, r *http.Request) {
@@ -109,6 +118,7 @@ func (self *WebApp) findFileVersionsHndl(w http.ResponseWriter, r *http.Request)
 		Path          string            `json:"path"`
 		CompareMethod string            `json:"compareMethod"`
 		DateRange     scanner.DateRange `json:"dateRange"`
+		Query         string            `json:"query"`
 	}
 
 	dateRange := scanner.NDaysBack(config.Get.DaysToScan, time.Now())
@@ -124,17 +134,25 @@ func (self *WebApp) findFileVersionsHndl(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		msg := fmt.Sprintf("Dataset for file: %s not found - %v", payload.Path, err)
 		log.Error(msg)
-		http.Error(w, msg, 400)
+		respondError(w, msg, err, 400)
+		return
+	}
+
+	query, err := zfs.ParseSnapshotQuery(payload.Query)
+	if err != nil {
+		msg := fmt.Sprintf("Invalid snapshot query: %s - %v", payload.Query, err)
+		log.Error(msg)
+		respondError(w, msg, err, 400)
 		return
 	}
 
 	// scan for other file versions
-	sc := scanner.NewScanner(payload.DateRange, payload.CompareMethod, ds, self.zfs)
-	scanResult, err := sc.FindFileVersions(payload.Path)
+	sc := scanner.NewScanner(payload.DateRange, payload.CompareMethod, ds, self.zfs, query)
+	scanResult, err := sc.FindFileVersions(r.Context(), payload.Path)
 	if err != nil {
 		msg := fmt.Sprintf("File versions search failed - %v", err)
 		log.Error(msg)
-		http.Error(w, msg, 500)
+		respondError(w, msg, err, 500)
 		return
 	}
 
@@ -157,7 +175,7 @@ func (self *WebApp) mimeTypeHndl(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := self.checkPathIsAllowed(payload.Path); err != nil {
-		http.Error(w, err.Error(), 400)
+		respondError(w, err.Error(), err, 400)
 		return
 	}
 
@@ -166,7 +184,7 @@ func (self *WebApp) mimeTypeHndl(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		msg := fmt.Sprintf("Unable to open the file: %s - %v", payload.Path, err)
 		log.Error(msg)
-		http.Error(w, msg, 500)
+		respondError(w, msg, err, 500)
 		return
 	}
 
@@ -175,7 +193,7 @@ func (self *WebApp) mimeTypeHndl(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		msg := fmt.Sprintf("Unable to determine the mime type for the file: %s - %v", payload.Path, err)
 		log.Error(msg)
-		http.Error(w, msg, 500)
+		respondError(w, msg, err, 500)
 		return
 	}
 
@@ -220,7 +238,7 @@ func (self *WebApp) downloadHndl(w http.ResponseWriter, r *http.Request) {
 	if len(payload.Path) == 0 {
 		msg := fmt.Sprintf("Unable to handle download - paramater 'path' missing")
 		log.Error(msg)
-		http.Error(w, msg, 400)
+		respondError(w, msg, nil, 400)
 		return
 	}
 
@@ -231,7 +249,7 @@ func (self *WebApp) downloadHndl(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := self.checkPathIsAllowed(payload.Path); err != nil {
-		http.Error(w, err.Error(), 400)
+		respondError(w, err.Error(), err, 400)
 		return
 	}
 
@@ -240,7 +258,7 @@ func (self *WebApp) downloadHndl(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		msg := fmt.Sprintf("Unable to open the file: %s - %v", payload.Path, err)
 		log.Error(msg)
-		http.Error(w, msg, 500)
+		respondError(w, msg, err, 500)
 		return
 	}
 
@@ -249,7 +267,7 @@ func (self *WebApp) downloadHndl(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		msg := fmt.Sprintf("Unable to determine the mime type for the file: %s - %v", payload.Path, err)
 		log.Error(msg)
-		http.Error(w, msg, 500)
+		respondError(w, msg, err, 500)
 		return
 	}
 
@@ -285,12 +303,12 @@ func (self *WebApp) diffHndl(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := self.checkPathIsAllowed(payload.CurrentPath); err != nil {
-		http.Error(w, err.Error(), 400)
+		respondError(w, err.Error(), err, 400)
 		return
 	}
 
 	if err := self.checkPathIsAllowed(payload.BackupPath); err != nil {
-		http.Error(w, err.Error(), 400)
+		respondError(w, err.Error(), err, 400)
 		return
 	}
 
@@ -299,7 +317,7 @@ func (self *WebApp) diffHndl(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		msg := fmt.Sprintf("Unable to create diff - %v", err)
 		log.Error(msg)
-		http.Error(w, msg, 400)
+		respondError(w, msg, err, 400)
 		return
 	}
 	respond(w, r, diffs)
@@ -324,15 +342,19 @@ func (self *WebApp) revertChangeHndl(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !checkLocalTransport(w, "revert the change") {
+		return
+	}
+
 	// valiate path
 	if err := self.checkPathIsAllowed(payload.CurrentPath); err != nil {
-		http.Error(w, err.Error(), 400)
+		respondError(w, err.Error(), err, 400)
 		return
 	}
 
 	// validate path
 	if err := self.checkPathIsAllowed(payload.BackupPath); err != nil {
-		http.Error(w, err.Error(), 400)
+		respondError(w, err.Error(), err, 400)
 		return
 	}
 
@@ -341,7 +363,7 @@ func (self *WebApp) revertChangeHndl(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		msg := fmt.Sprintf("Unable to create diff - %v", err)
 		log.Error(msg)
-		http.Error(w, msg, 400)
+		respondError(w, msg, err, 400)
 		return
 	}
 
@@ -351,7 +373,7 @@ func (self *WebApp) revertChangeHndl(w http.ResponseWriter, r *http.Request) {
 	if backup, err = fh.Backup(); err != nil {
 		msg := fmt.Sprintf("Unable to backup the file - %v", err)
 		log.Error(msg)
-		http.Error(w, msg, 400)
+		respondError(w, msg, err, 400)
 		return
 	}
 
@@ -360,7 +382,7 @@ func (self *WebApp) revertChangeHndl(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		msg := fmt.Sprintf("Unable to revert change - %v", err)
 		log.Error(msg)
-		http.Error(w, msg, 400)
+		respondError(w, msg, err, 400)
 		return
 	}
 
@@ -386,15 +408,19 @@ func (self *WebApp) restoreFileHndl(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !checkLocalTransport(w, "restore the file") {
+		return
+	}
+
 	// valiate path
 	if err := self.checkPathIsAllowed(payload.CurrentPath); err != nil {
-		http.Error(w, err.Error(), 400)
+		respondError(w, err.Error(), err, 400)
 		return
 	}
 
 	// validate path
 	if err := self.checkPathIsAllowed(payload.BackupPath); err != nil {
-		http.Error(w, err.Error(), 400)
+		respondError(w, err.Error(), err, 400)
 		return
 	}
 
@@ -403,7 +429,7 @@ func (self *WebApp) restoreFileHndl(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		msg := fmt.Sprintf("Unable to open current file - %v", err)
 		log.Error(msg)
-		http.Error(w, msg, 400)
+		respondError(w, msg, err, 400)
 		return
 	}
 
@@ -412,7 +438,7 @@ func (self *WebApp) restoreFileHndl(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		msg := fmt.Sprintf("Unable to open backup file - %v", err)
 		log.Error(msg)
-		http.Error(w, msg, 400)
+		respondError(w, msg, err, 400)
 		return
 	}
 
@@ -421,7 +447,7 @@ func (self *WebApp) restoreFileHndl(w http.ResponseWriter, r *http.Request) {
 	if backup, err = currentFh.Backup(); err != nil {
 		msg := fmt.Sprintf("Unable to backup the file - %v", err)
 		log.Error(msg)
-		http.Error(w, msg, 400)
+		respondError(w, msg, err, 400)
 		return
 	}
 
@@ -429,7 +455,7 @@ func (self *WebApp) restoreFileHndl(w http.ResponseWriter, r *http.Request) {
 	if err := backupFh.Copy(payload.CurrentPath); err != nil {
 		msg := fmt.Sprintf("Unable to restore the file - %v", err)
 		log.Error(msg)
-		http.Error(w, msg, 400)
+		respondError(w, msg, err, 400)
 		return
 	}
 
@@ -474,13 +500,13 @@ func (self *WebApp) prepareArchiveHndl(w http.ResponseWriter, r *http.Request) {
 	if len(payload.Path) == 0 {
 		msg := fmt.Sprintf("Unable to prepare archive - Paramater 'path' missing")
 		log.Error(msg)
-		http.Error(w, msg, 400)
+		respondError(w, msg, nil, 400)
 		return
 	}
 
 	// valiate path
 	if err := self.checkPathIsAllowed(payload.Path); err != nil {
-		http.Error(w, err.Error(), 400)
+		respondError(w, err.Error(), err, 400)
 		return
 	}
 
@@ -488,7 +514,7 @@ func (self *WebApp) prepareArchiveHndl(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		msg := fmt.Sprintf("Requested path not found - %v", err)
 		log.Error(msg)
-		http.Error(w, msg, 500)
+		respondError(w, msg, err, 500)
 		return
 	}
 
@@ -501,7 +527,7 @@ func (self *WebApp) prepareArchiveHndl(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		msg := fmt.Sprintf("Unable to create the archive - %v", err)
 		log.Error(msg)
-		http.Error(w, msg, 500)
+		respondError(w, msg, err, 500)
 		return
 	}
 
@@ -515,7 +541,7 @@ func (self *WebApp) downloadArchiveHndl(w http.ResponseWriter, r *http.Request)
 	} else {
 		msg := "Unable to serve archive - Paramater 'name' missing"
 		log.Error(msg)
-		http.Error(w, msg, 400)
+		respondError(w, msg, nil, 400)
 		return
 	}
 
@@ -524,7 +550,7 @@ func (self *WebApp) downloadArchiveHndl(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		msg := fmt.Sprintf("Unable to find the archive - %v", err)
 		log.Error(msg)
-		http.Error(w, msg, 500)
+		respondError(w, msg, err, 500)
 		return
 	}
 	log.Infof("serve archive: %s", archive.Path)
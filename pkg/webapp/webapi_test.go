@@ -0,0 +1,23 @@
+package webapp
+
+import (
+	"github.com/j-keck/zfs-snap-diff/pkg/config"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWritesRefusedPerTransport(t *testing.T) {
+	app, cleanup := newTestWebApp(t)
+	defer cleanup()
+
+	defer func(cfg config.TransportConfig) { config.Get.Transport = cfg }(config.Get.Transport)
+	config.Get.Transport = config.TransportConfig{Wrapper: config.Argv{"ssh", "host"}}
+
+	for _, hndl := range []http.HandlerFunc{app.restoreFileHndl, app.revertChangeHndl} {
+		w := post(hndl, `{"currentPath": "/tank/fs1/file", "backupPath": "/tank/fs1/.zfs/snapshot/one/file"}`)
+		if w.Code != 501 || !strings.Contains(w.Body.String(), "ssh host") {
+			t.Errorf("write per transport not refused: %d - %s", w.Code, w.Body)
+		}
+	}
+}
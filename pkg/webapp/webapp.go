@@ -3,6 +3,7 @@ package webapp
 import (
 	"github.com/j-keck/plog"
 	"github.com/j-keck/zfs-snap-diff/pkg/config"
+	"github.com/j-keck/zfs-snap-diff/pkg/scheduler"
 	"github.com/j-keck/zfs-snap-diff/pkg/zfs"
 	"net/http"
 	"path/filepath"
@@ -12,12 +13,20 @@ import (
 var log = plog.GlobalLogger()
 
 type WebApp struct {
-	zfs zfs.ZFS
+	zfs       zfs.ZFS
+	scheduler *scheduler.Scheduler
+	sends     *Progresses
+	// principal is the user which executes the zfs commands.
+	// operations are not checked against the delegated permissions if it's nil.
+	principal *zfs.Principal
 }
 
-func NewWebApp(zfs zfs.ZFS) WebApp {
+func NewWebApp(zfs zfs.ZFS, scheduler *scheduler.Scheduler) WebApp {
 	self := new(WebApp)
 	self.zfs = zfs
+	self.scheduler = scheduler
+	self.sends = NewProgresses()
+	self.principal = lookupPrincipal()
 	self.registerAssetsEndpoint()
 	self.registerApiEndpoints()
 	return *self
@@ -72,15 +81,35 @@ func (self *WebApp) registerAssetsEndpoint() {
 func (self *WebApp) registerApiEndpoints() {
 	http.HandleFunc("/api/config", self.configHndl)
 	http.HandleFunc("/api/rescan-datasets", self.rescanDatasetsHndl)
+	http.HandleFunc("/api/dataset-properties", self.datasetPropertiesHndl)
+	http.HandleFunc("/api/set-dataset-property", self.setDatasetPropertyHndl)
+	http.HandleFunc("/api/capabilities", self.capabilitiesHndl)
+	http.HandleFunc("/api/unlock-dataset", self.unlockDatasetHndl)
 	http.HandleFunc("/api/stat", self.statHndl)
 	http.HandleFunc("/api/dir-listing", self.dirListingHndl)
 	http.HandleFunc("/api/find-file-versions", self.findFileVersionsHndl)
 	http.HandleFunc("/api/snapshots-for-dataset", self.snapshotsForDatasetHndl)
+	http.HandleFunc("/api/bookmarks-for-dataset", self.bookmarksForDatasetHndl)
+	http.HandleFunc("/api/create-bookmark", self.createBookmarkHndl)
+	http.HandleFunc("/api/destroy-bookmark", self.destroyBookmarkHndl)
 	http.HandleFunc("/api/create-snapshot", self.createSnapshotHndl)
 	http.HandleFunc("/api/destroy-snapshot", self.destroySnapshotHndl)
 	http.HandleFunc("/api/rename-snapshot", self.renameSnapshotHndl)
 	http.HandleFunc("/api/clone-snapshot", self.cloneSnapshotHndl)
+	http.HandleFunc("/api/clones-for-dataset", self.clonesForDatasetHndl)
+	http.HandleFunc("/api/promote-clone", self.promoteCloneHndl)
+	http.HandleFunc("/api/destroy-clone", self.destroyCloneHndl)
 	http.HandleFunc("/api/rollback-snapshot", self.rollbackSnapshotHndl)
+	http.HandleFunc("/api/snapshot-schedules", self.snapshotSchedulesHndl)
+	http.HandleFunc("/api/retention-plan", self.retentionPlanHndl)
+	http.HandleFunc("/api/apply-retention", self.applyRetentionHndl)
+	http.HandleFunc("/api/holds-for-snapshot", self.holdsForSnapshotHndl)
+	http.HandleFunc("/api/hold-snapshot", self.holdSnapshotHndl)
+	http.HandleFunc("/api/release-snapshot", self.releaseSnapshotHndl)
+	http.HandleFunc("/api/snapshot-diff", self.snapshotDiffHndl)
+	http.HandleFunc("/api/send-snapshot", self.sendSnapshotHndl)
+	http.HandleFunc("/api/send-snapshot-progress", self.sendSnapshotProgressHndl)
+	http.HandleFunc("/api/receive-snapshot", self.receiveSnapshotHndl)
 	http.HandleFunc("/api/mime-type", self.mimeTypeHndl)
 	http.HandleFunc("/api/download", self.downloadHndl)
 	http.HandleFunc("/api/diff", self.diffHndl)
@@ -1,17 +1,22 @@
 package webapp
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"github.com/j-keck/zfs-snap-diff/pkg/transport"
+	"github.com/j-keck/zfs-snap-diff/pkg/zfs"
 	"net/http"
+	"strings"
 )
 
 func (self *WebApp) checkPathIsAllowed(path string) error {
-	log.Tracef("check if '%s' lives under the current dataset: '%s'",
-		path, self.zfs.Name())
+	datasetNames := strings.Join(self.zfs.Names(), ", ")
+	log.Tracef("check if '%s' lives under the current dataset(s): '%s'", path, datasetNames)
 	if _, err := self.zfs.FindDatasetForPath(path); err != nil {
 		msg := "Requested path was not in the dataset"
-		log.Errorf("%s - path: '%s', dataset: '%s'", msg, path, self.zfs.Name())
+		log.Errorf("%s - path: '%s', dataset(s): '%s'", msg, path, datasetNames)
 		return errors.New(msg)
 	}
 	return nil
@@ -21,7 +26,7 @@ func decodeJsonPayload(w http.ResponseWriter, r *http.Request, payload interface
 	decoder := json.NewDecoder(r.Body)
 	if err := decoder.Decode(payload); err != nil {
 		log.Errorf("Decoding payload error - request at: %s, error: %v", r.URL, err)
-		http.Error(w, "Invalid payload", 400)
+		respondError(w, "Invalid payload", err, 400)
 		return nil
 	}
 	log.Tracef("decodeJsonPayload for request at: %s - payload: %+v", r.URL, payload)
@@ -46,6 +51,100 @@ func respond(w http.ResponseWriter, r *http.Request, payload interface{}) {
 	} else {
 		msg := "Unable to marshal response payload as json"
 		log.Errorf("%s - %v", msg, err)
-		http.Error(w, msg, 500)
+		respondError(w, msg, err, 500)
+	}
+}
+
+// lookupPrincipal returns the user which executes the zfs commands - nil if unknown
+func lookupPrincipal() *zfs.Principal {
+	principal, err := zfs.CurrentPrincipal()
+	if err != nil {
+		log.Warnf("unable to determine the current user - "+
+			"operations are not checked against the delegated permissions - %v", err)
+		return nil
+	}
+	log.Debugf("execute zfs commands as: %+v", principal)
+	return &principal
+}
+
+// checkPermitted refuses the request if the operation is not permitted
+// per the delegated permissions ('zfs allow') on the dataset.
+// Returns false if the request was refused.
+//
+// If the permissions can't be determined, the operation is attempted.
+func (self *WebApp) checkPermitted(w http.ResponseWriter, r *http.Request, ds zfs.Dataset, op string) bool {
+	if err := self.permitted(r.Context(), ds, op); err != nil {
+		msg := fmt.Sprintf("Refused - %v", err)
+		log.Error(msg)
+		respondError(w, msg, err, 403)
+		return false
+	}
+	return true
+}
+
+// checkLocalTransport refuses the request if the files are not locally accessible.
+// Returns false if the request was refused.
+//
+// the files are read per the transport, but written locally only.
+func checkLocalTransport(w http.ResponseWriter, action string) bool {
+	if tp := transport.Get(); !tp.IsLocal() {
+		msg := fmt.Sprintf("Refused - unable to %s per the transport: '%s' - only reads are supported", action, tp)
+		log.Error(msg)
+		respondError(w, msg, nil, 501)
+		return false
+	}
+	return true
+}
+
+// permitted returns an error if the operation is not permitted
+// per the delegated permissions on the dataset - nil if the permissions can't be determined.
+func (self *WebApp) permitted(ctx context.Context, ds zfs.Dataset, op string) error {
+	if self.principal == nil {
+		return nil
+	}
+
+	caps, err := ds.Capabilities(ctx, *self.principal)
+	if err != nil {
+		log.Warnf("unable to determine the delegated permissions on dataset: %s - %v", ds.Name, err)
+		return nil
+	}
+	return caps.Check(op, ds.Name)
+}
+
+// ErrorResponse is the json body from failed requests
+type ErrorResponse struct {
+	Message string `json:"message"`
+	// Kind classifies failed zfs commands - like "not-found" or "busy"
+	Kind zfs.ErrorKind `json:"kind,omitempty"`
+}
+
+// respondError responds with a json error body - see 'ErrorResponse'.
+//
+// Classified zfs errors are mapped to their status code:
+//
+//	not-found:                         404
+//	permission-denied:                 403
+//	busy, has-clones, hold-present:    409
+//	pool-suspended, timeout:           503
+//
+// 'code' is used for all other errors.
+func respondError(w http.ResponseWriter, msg string, err error, code int) {
+	kind := zfs.KindOf(err)
+	switch kind {
+	case zfs.ErrorNotFound:
+		code = http.StatusNotFound
+	case zfs.ErrorPermissionDenied:
+		code = http.StatusForbidden
+	case zfs.ErrorBusy, zfs.ErrorHasClones, zfs.ErrorHoldPresent:
+		code = http.StatusConflict
+	case zfs.ErrorPoolSuspended, zfs.ErrorTimeout:
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(ErrorResponse{msg, kind}); err != nil {
+		log.Errorf("unable to write the error response - %v", err)
 	}
 }
@@ -0,0 +1,98 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bookmark - zfs bookmark
+type Bookmark struct {
+	Name     string    `json:"name"`
+	FullName string    `json:"fullName"`
+	Created  time.Time `json:"created"`
+}
+
+// Bookmarks represents bookmarks from a zfs dataset
+type Bookmarks []Bookmark
+
+// ScanBookmarks returns a list of all bookmarks for this dataset - newest first
+func (self *Dataset) ScanBookmarks(ctx context.Context) (Bookmarks, error) {
+	stdout, _, err := self.cmd.Exec(ctx, "list -t bookmark -s creation -r -d 1 -o name,creation -Hp", self.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	parse := func(s string) (string, time.Time, bool) {
+		const n = 2
+		fields := strings.SplitN(s, "\t", n)
+		if len(fields) == n {
+			n, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				log.Errorf("unable to convert '%s' to a number: %s", fields[1], err.Error())
+				return "", time.Unix(0, 0), false
+			}
+			return fields[0], time.Unix(n, 0), true
+		} else {
+			return "", time.Unix(0, 0), false
+		}
+	}
+
+	bookmarks := Bookmarks{}
+	for _, line := range strings.Split(stdout, "\n") {
+		if fullName, creation, ok := parse(line); ok {
+			// remove dataset name from bookmark
+			fields := strings.Split(fullName, "#")
+			name := fields[len(fields)-1]
+
+			// prepend - 'zfs list' returns the oldest bookmark first
+			bookmarks = append(Bookmarks{Bookmark{name, fullName, creation}}, bookmarks...)
+		}
+	}
+	return bookmarks, nil
+}
+
+// CreateBookmark creates a bookmark from the given snapshot
+func (self *Dataset) CreateBookmark(ctx context.Context, snapName, name string) (string, error) {
+	if len(name) == 0 {
+		return "", errors.New("bookmark-name can't be empty")
+	}
+
+	if !strings.HasPrefix(snapName, self.Name) {
+		snapName = self.Name + "@" + snapName
+	}
+
+	if !strings.HasPrefix(name, self.Name) {
+		name = self.Name + "#" + name
+	}
+
+	log.Debugf("create bookmark: %s from snapshot: %s", name, snapName)
+	stdout, stderr, err := self.cmd.Exec(ctx, "bookmark", snapName, name)
+	log.Tracef("create bookmark stdout: %s", stdout)
+	log.Tracef("create bookmark stderr: %s", stderr)
+	return name, err
+}
+
+// DestroyBookmark destroys the given bookmark
+func (self *Dataset) DestroyBookmark(ctx context.Context, name string) error {
+	if len(name) == 0 {
+		return errors.New("bookmark-name can't be empty")
+	}
+
+	if !strings.HasPrefix(name, self.Name) {
+		name = self.Name + "#" + name
+	}
+
+	// prevent destroying anything else than a bookmark
+	if !strings.Contains(name, "#") {
+		return errors.New("'" + name + "' is not a bookmark")
+	}
+
+	log.Debugf("destroy bookmark: %s", name)
+	stdout, stderr, err := self.cmd.Exec(ctx, "destroy", name)
+	log.Tracef("destroy bookmark stdout: %s", stdout)
+	log.Tracef("destroy bookmark stderr: %s", stderr)
+	return err
+}
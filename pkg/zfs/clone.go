@@ -0,0 +1,101 @@
+package zfs
+
+import (
+	"context"
+	"fmt"
+)
+
+// Clone - a dataset which was cloned from a snapshot
+type Clone struct {
+	Name string `json:"name"`
+	// Origin is the full name from the snapshot
+	Origin string `json:"origin"`
+}
+
+// Clones are the clones from the snapshots of a dataset
+type Clones []Clone
+
+// ListClones returns the clones from all snapshots of this dataset - oldest snapshot first.
+//
+// The snapshot list is not served from the cache - clones are often
+// created or destroyed outside from zfs-snap-diff.
+func (self *Dataset) ListClones(ctx context.Context) (Clones, error) {
+	snaps, err := self.scanSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	clones := Clones{}
+	for _, snap := range snaps.Reverse() {
+		for _, name := range snap.Clones {
+			clones = append(clones, Clone{name, snap.FullName})
+		}
+	}
+	return clones, nil
+}
+
+// PromoteClone promotes the given clone from a snapshot of this dataset.
+//
+// The snapshots up to the origin are moved to the clone,
+// and this dataset becomes a clone from the promoted dataset.
+func (self *Dataset) PromoteClone(ctx context.Context, cloneName string) error {
+	if err := self.checkClone(ctx, cloneName); err != nil {
+		return err
+	}
+
+	defer self.cache.invalidate(self.Name)
+	defer self.cache.invalidate(cloneName)
+	log.Debugf("promote clone: %s", cloneName)
+	stdout, stderr, err := self.cmd.Exec(ctx, "promote", cloneName)
+	log.Tracef("promote clone stdout: %s", stdout)
+	log.Tracef("promote clone stderr: %s", stderr)
+	return err
+}
+
+// DestroyClone destroys the given clone from a snapshot of this dataset.
+//
+// Use the flag '-r' to destroy the snapshots and children of the clone too.
+func (self *Dataset) DestroyClone(ctx context.Context, cloneName string, flags []string) error {
+	if err := self.checkClone(ctx, cloneName); err != nil {
+		return err
+	}
+
+	// the clones property from the origin and the snapshots from the clone
+	// (and with '-R' from the dependent clones) have changed
+	defer self.cache.invalidate(self.Name)
+	defer self.cache.invalidateWithFlags(cloneName, flags)
+	log.Debugf("destroy clone: %s", cloneName)
+	args := append(flags, cloneName)
+	stdout, stderr, err := self.cmd.Exec(ctx, "destroy", args...)
+	log.Tracef("destroy clone stdout: %s", stdout)
+	log.Tracef("destroy clone stderr: %s", stderr)
+	return err
+}
+
+// LookupClone returns the given clone from a snapshot of this dataset.
+//
+// The clone can live outside the dataset trees from zfs-snap-diff - use it
+// to check the delegated permissions on the clone (see 'Dataset.Capabilities').
+func (self *Dataset) LookupClone(ctx context.Context, cloneName string) (Dataset, error) {
+	if err := self.checkClone(ctx, cloneName); err != nil {
+		return Dataset{}, err
+	}
+	return Dataset{Name: cloneName, cmd: self.cmd, cache: self.cache}, nil
+}
+
+// checkClone verifies that the given dataset is a clone from a snapshot of this dataset.
+// this prevents that arbitrary datasets are promoted or destroyed.
+func (self *Dataset) checkClone(ctx context.Context, cloneName string) error {
+	clones, err := self.ListClones(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range clones {
+		if c.Name == cloneName {
+			return nil
+		}
+	}
+	err = fmt.Errorf("dataset: '%s' is not a clone from a snapshot of: '%s'", cloneName, self.Name)
+	return ExecZFSError{err, ErrorNotFound}
+}
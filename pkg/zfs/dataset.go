@@ -1,7 +1,9 @@
 package zfs
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/j-keck/zfs-snap-diff/pkg/fs"
 	"strconv"
 	"strings"
@@ -9,64 +11,136 @@ import (
 )
 
 // Dataset represents a zfs dataset (aka. zfs filesystem)
+//
+// Origin is the snapshot from which the dataset was cloned - empty if it's not a clone.
+//
+// Encryption, KeyStatus and KeyLocation are empty for unencrypted datasets.
+// Locked datasets (the encryption key is not loaded) are not mounted - their
+// MountPoint is the configured mountpoint, which is not accessible.
 type Dataset struct {
-	Name       string       `json:"name"`
-	Used       uint64       `json:"used"`
-	Avail      uint64       `json:"avail"`
-	Refer      uint64       `json:"refer"`
-	MountPoint fs.DirHandle `json:"mountPoint"`
-	cmd        ZFSCmd
+	Name        string       `json:"name"`
+	Used        uint64       `json:"used"`
+	Avail       uint64       `json:"avail"`
+	Refer       uint64       `json:"refer"`
+	Origin      string       `json:"origin,omitempty"`
+	Encryption  string       `json:"encryption,omitempty"`
+	KeyStatus   string       `json:"keyStatus,omitempty"`
+	KeyLocation string       `json:"keyLocation,omitempty"`
+	Locked      bool         `json:"locked"`
+	MountPoint  fs.DirHandle `json:"mountPoint"`
+	BindMounts  []string     `json:"bindMounts,omitempty"`
+	cmd         ZFSCmd
+	cache       *SnapshotCache
 }
 
 // ScanSnapshots returns a list of all snapshots for this dataset
-func (self *Dataset) ScanSnapshots() (Snapshots, error) {
-	stdout, stderr, err := self.cmd.Exec("list -t snapshot -s creation -r -d 1 -o name,creation -Hp", self.Name)
+//
+// The result is served from the snapshot cache if it's not expired.
+func (self *Dataset) ScanSnapshots(ctx context.Context) (Snapshots, error) {
+	if snaps, ok := self.cache.get(self.Name); ok {
+		return snaps, nil
+	}
+
+	snaps, err := self.scanSnapshots(ctx)
+	if err == nil {
+		self.cache.put(self.Name, snaps)
+	}
+	return snaps, err
+}
+
+// snapshotColumns are the properties from the 'zfs list' call in 'scanSnapshots'
+var snapshotColumns = []string{"name", "creation", "userrefs", "used", "referenced", "written", "createtxg", "guid", "clones"}
+
+func (self *Dataset) scanSnapshots(ctx context.Context) (Snapshots, error) {
+	stdout, _, err := self.cmd.Exec(ctx,
+		"list -t snapshot -s creation -r -d 1 -o "+strings.Join(snapshotColumns, ",")+" -Hp", self.Name)
 	if err != nil {
-		return nil, errors.New(stderr)
+		return nil, err
 	}
 
-	parse := func(s string) (string, time.Time, bool) {
-		const n = 2
-		fields := strings.SplitN(s, "\t", n)
-		if len(fields) == n {
-			n, err := strconv.ParseInt(fields[1], 10, 64)
-			if err != nil {
-				log.Errorf("unable to convert '%s' to a number: %s", fields[1], err.Error())
-				return "", time.Unix(0, 0), false
+	// parse a line from the zfs output
+	parse := func(s string) (Snapshot, bool) {
+		fields := strings.SplitN(s, "\t", len(snapshotColumns))
+		if len(fields) != len(snapshotColumns) {
+			return Snapshot{}, false
+		}
+
+		snap := Snapshot{Clones: []string{}}
+		for i, column := range snapshotColumns {
+			value := fields[i]
+			switch column {
+			case "name":
+				snap.FullName = value
+			case "creation":
+				creation, err := strconv.ParseInt(value, 10, 64)
+				if err != nil {
+					log.Errorf("unable to convert '%s' to a number: %s", value, err.Error())
+					return Snapshot{}, false
+				}
+				snap.Created = time.Unix(creation, 0)
+			case "userrefs":
+				if snap.Holds, err = strconv.Atoi(value); err != nil {
+					log.Errorf("unable to convert '%s' to a number: %s", value, err.Error())
+					return Snapshot{}, false
+				}
+			case "used", "referenced", "written", "createtxg":
+				n, err := strconv.ParseUint(value, 10, 64)
+				if err != nil {
+					log.Errorf("unable to convert '%s' to a number: %s", value, err.Error())
+					return Snapshot{}, false
+				}
+				switch column {
+				case "used":
+					snap.Used = n
+				case "referenced":
+					snap.Referenced = n
+				case "written":
+					snap.Written = n
+				case "createtxg":
+					snap.CreateTXG = n
+				}
+			case "guid":
+				snap.GUID = value
+			case "clones":
+				if value != "" && value != "-" {
+					snap.Clones = strings.Split(value, ",")
+				}
 			}
-			return fields[0], time.Unix(n, 0), true
-		} else {
-			return "", time.Unix(0, 0), false
 		}
+		return snap, true
 	}
 
 	snapshots := Snapshots{}
 	for _, line := range strings.Split(stdout, "\n") {
-		if fullName, creation, ok := parse(line); ok {
+		if snap, ok := parse(line); ok {
 			// remove dataset name from snapshot
-			fields := strings.Split(fullName, "@")
-			name := fields[len(fields)-1]
+			fields := strings.Split(snap.FullName, "@")
+			snap.Name = fields[len(fields)-1]
 
 			// create the dir-handle per hand.
 			// this prevents a unnecessary 'os.Stat' call for each snapshot
 			// (which takes some time with thousands snapshots on spinning disks)
-			dir := fs.DirHandle{fs.FSHandle{
-				Name:  name,
-				Path:  self.MountPoint.Path + "/.zfs/snapshot/" + name,
+			snap.MountPoint = fs.DirHandle{FSHandle: fs.FSHandle{
+				Name:  snap.Name,
+				Path:  self.MountPoint.Path + "/.zfs/snapshot/" + snap.Name,
 				Kind:  fs.DIR,
 				Size:  0,
-				MTime: creation,
+				MTime: snap.Created,
 			}}
 
 			// append new snap to snapshots
-			snapshots = append(snapshots, Snapshot{name, fullName, creation, dir})
+			snapshots = append(snapshots, snap)
 		}
 
 	}
 	return snapshots.Reverse(), nil
 }
 
-func (self *Dataset) CreateSnapshot(name string) (string, error) {
+// CreateSnapshot creates a snapshot with the given name.
+//
+// Use the flag '-r' to create the snapshot recursive for all descendent datasets.
+// The given user properties are set at creation.
+func (self *Dataset) CreateSnapshot(ctx context.Context, name string, flags []string, props map[string]string) (string, error) {
 	if len(name) == 0 {
 		return "", errors.New("snapshot-name can't be empty")
 	}
@@ -75,14 +149,11 @@ func (self *Dataset) CreateSnapshot(name string) (string, error) {
 		name = self.Name + "@" + name
 	}
 
-	log.Debugf("create snapshot: %s", name)
-	stdout, stderr, err := self.cmd.Exec("snapshot", name)
-	log.Tracef("create snapshot stdout: %s", stdout)
-	log.Tracef("create snapshot stderr: %s", stderr)
-	return name, err
+	defer self.cache.invalidate(self.Name)
+	return name, createSnapshots(ctx, self.cmd, []string{name}, flags, props)
 }
 
-func (self *Dataset) CloneSnapshot(snapName, fsName string, flags []string) error {
+func (self *Dataset) CloneSnapshot(ctx context.Context, snapName, fsName string, flags []string) error {
 	if len(fsName) == 0 {
 		return errors.New("filesystem-name can't be empty")
 	}
@@ -91,16 +162,17 @@ func (self *Dataset) CloneSnapshot(snapName, fsName string, flags []string) erro
 		snapName = self.Name + "@" + snapName
 	}
 
+	defer self.cache.invalidate(self.Name)
 	log.Debugf("clone snapshot: %s to %s", snapName, fsName)
 	args := append(flags, snapName, fsName)
-	stdout, stderr, err := self.cmd.Exec("clone", args...)
+	stdout, stderr, err := self.cmd.Exec(ctx, "clone", args...)
 	log.Tracef("clone snapshot stdout: %s", stdout)
 	log.Tracef("clone snapshot stderr: %s", stderr)
 	return err
 }
 
 // FIXME: check if the given name is a snapshot name
-func (self *Dataset) RenameSnapshot(oldName, newName string) error {
+func (self *Dataset) RenameSnapshot(ctx context.Context, oldName, newName string) error {
 	if len(newName) == 0 {
 		return errors.New("new snapshot-name can't be empty")
 	}
@@ -113,34 +185,45 @@ func (self *Dataset) RenameSnapshot(oldName, newName string) error {
 		newName = self.Name + "@" + newName
 	}
 
+	defer self.cache.invalidate(self.Name)
 	log.Debugf("rename snapshot: %s -> %s", oldName, newName)
-	stdout, stderr, err := self.cmd.Exec("rename", oldName, newName)
+	stdout, stderr, err := self.cmd.Exec(ctx, "rename", oldName, newName)
 	log.Tracef("rename snapshot stdout: %s", stdout)
 	log.Tracef("rename snapshot stderr: %s", stderr)
 	return err
 }
 
-func (self *Dataset) DestroySnapshot(name string, flags []string) error {
+func (self *Dataset) DestroySnapshot(ctx context.Context, name string, flags []string) error {
 	if !strings.HasPrefix(name, self.Name) {
 		name = self.Name + "@" + name
 	}
 
+	defer self.cache.invalidateWithFlags(self.Name, flags)
 	log.Debugf("destroy snapshot: %s", name)
 	args := append(flags, name)
-	stdout, stderr, err := self.cmd.Exec("destroy", args...)
+	stdout, stderr, err := self.cmd.Exec(ctx, "destroy", args...)
 	log.Tracef("destroy snapshot stdout: %s", stdout)
 	log.Tracef("destroy snapshot stderr: %s", stderr)
+
+	// zfs reports a held snapshot as busy - tell the user which holds to release
+	if KindOf(err) == ErrorBusy {
+		if holds, e := self.ListHolds(ctx, name); e == nil && len(holds) > 0 {
+			err = fmt.Errorf("release the hold(s) at first: %s - %v", strings.Join(holds.Tags(), ", "), err)
+			return ExecZFSError{err, ErrorHoldPresent}
+		}
+	}
 	return err
 }
 
-func (self *Dataset) RollbackSnapshot(name string, flags []string) error {
+func (self *Dataset) RollbackSnapshot(ctx context.Context, name string, flags []string) error {
 	if !strings.HasPrefix(name, self.Name) {
 		name = self.Name + "@" + name
 	}
 
+	defer self.cache.invalidateWithFlags(self.Name, flags)
 	log.Debugf("rollback snapshot: %s", name)
 	args := append(flags, name)
-	stdout, stderr, err := self.cmd.Exec("rollback", args...)
+	stdout, stderr, err := self.cmd.Exec(ctx, "rollback", args...)
 	log.Tracef("rollback snapshot stdout: %s", stdout)
 	log.Tracef("rollback snapshot stderr: %s", stderr)
 	return err
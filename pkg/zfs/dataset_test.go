@@ -1,19 +1,22 @@
 package zfs
 
 import (
+	"context"
+	"github.com/j-keck/zfs-snap-diff/pkg/fs"
 	"testing"
+	"time"
 )
 
 func TestScanSnapshots(t *testing.T) {
-	out := `tank/fs1@one	1
-tank/fs1@two	2
-tank/fs1@three	3`
+	out := `tank/fs1@one	1	0	1024	4096	4096	10	1234	
+tank/fs1@two	2	1	0	4096	0	11	5678	tank/clone1,tank/clone2
+tank/fs1@three	3	0	2048	8192	4096	12	18446744073709551615	`
 
 	ds := new(Dataset)
 	ds.Name = "tank"
 	ds.cmd = NewZFSCmdMock(out, "", nil)
 
-	snaps, err := ds.ScanSnapshots()
+	snaps, err := ds.ScanSnapshots(context.Background())
 	if err != nil {
 		t.Error(err)
 	}
@@ -22,4 +25,129 @@ tank/fs1@three	3`
 	if len(snaps) != expected {
 		t.Errorf("%d snapshots found - expected %d", len(snaps), expected)
 	}
+
+	if snaps[1].Holds != 1 {
+		t.Errorf("%d holds found - expected 1", snaps[1].Holds)
+	}
+
+	if len(snaps[1].Clones) != 2 {
+		t.Errorf("%d clones found - expected 2", len(snaps[1].Clones))
+	}
+
+	if snaps[0].Used != 2048 {
+		t.Errorf("%d bytes used - expected 2048", snaps[0].Used)
+	}
+
+	if s := snaps[0]; s.Referenced != 8192 || s.Written != 4096 || s.CreateTXG != 12 || s.GUID != "18446744073709551615" {
+		t.Errorf("unexpected space accounting: %+v", s)
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	out := "1561563437.151553545\tM\t/\t/tank/fs1\n" +
+		"1561563437.151553545\t+\tF\t/tank/fs1/new\\040file\n" +
+		"1561563420.000000000\t-\t@\t/tank/fs1/link\n" +
+		"1561563437.151553545\tR\tF\t/tank/fs1/old\t/tank/fs1/renamed"
+
+	ds := new(Dataset)
+	ds.Name = "tank/fs1"
+	ds.cmd = NewZFSCmdMock(out, "", nil)
+
+	changes, err := ds.DiffSnapshots(context.Background(), "one", "two")
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := FileChanges{
+		{Change: Modified, Kind: fs.DIR, Path: "/tank/fs1", CTime: time.Unix(1561563437, 151553545)},
+		{Change: Added, Kind: fs.FILE, Path: "/tank/fs1/new file", CTime: time.Unix(1561563437, 151553545)},
+		{Change: Removed, Kind: fs.LINK, Path: "/tank/fs1/link", CTime: time.Unix(1561563420, 0)},
+		{Change: Renamed, Kind: fs.FILE, Path: "/tank/fs1/old", NewPath: "/tank/fs1/renamed",
+			CTime: time.Unix(1561563437, 151553545)},
+	}
+
+	if len(changes) != len(expected) {
+		t.Fatalf("%d changes found - expected %d", len(changes), len(expected))
+	}
+
+	for i := range expected {
+		if changes[i] != expected[i] {
+			t.Errorf("unexpected change: %+v - expected: %+v", changes[i], expected[i])
+		}
+	}
+}
+
+func TestListHolds(t *testing.T) {
+	out := "tank/fs1@one\tkeep\tWed Oct 18 12:05 2023\n" +
+		"tank/fs1@one\tupgrade\tThu Oct  5 08:15 2023"
+
+	ds := new(Dataset)
+	ds.Name = "tank/fs1"
+	ds.cmd = NewZFSCmdMock(out, "", nil)
+
+	holds, err := ds.ListHolds(context.Background(), "one")
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := Holds{
+		{"tank/fs1@one", "keep", time.Date(2023, 10, 18, 12, 5, 0, 0, time.Local)},
+		{"tank/fs1@one", "upgrade", time.Date(2023, 10, 5, 8, 15, 0, 0, time.Local)},
+	}
+
+	if len(holds) != len(expected) {
+		t.Fatalf("%d holds found - expected %d", len(holds), len(expected))
+	}
+
+	for i := range expected {
+		if holds[i] != expected[i] {
+			t.Errorf("unexpected hold: %+v - expected: %+v", holds[i], expected[i])
+		}
+	}
+
+	for _, tag := range []string{"", "-r"} {
+		if err := ds.HoldSnapshot(context.Background(), "one", tag, nil); err == nil {
+			t.Errorf("invalid hold-tag: '%s' accepted", tag)
+		}
+	}
+}
+
+func TestScanBookmarks(t *testing.T) {
+	out := `tank/fs1#one	1
+tank/fs1#two	2`
+
+	ds := new(Dataset)
+	ds.Name = "tank/fs1"
+	ds.cmd = NewZFSCmdMock(out, "", nil)
+
+	bookmarks, err := ds.ScanBookmarks(context.Background())
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := 2
+	if len(bookmarks) != expected {
+		t.Fatalf("%d bookmarks found - expected %d", len(bookmarks), expected)
+	}
+
+	if bookmarks[0].Name != "two" {
+		t.Errorf("newest bookmark expected first - got: %s", bookmarks[0].Name)
+	}
+}
+
+func TestEstimateSendSize(t *testing.T) {
+	out := "incremental\tone\ttank/fs1@two\t4096\nsize\t4096"
+
+	ds := new(Dataset)
+	ds.Name = "tank/fs1"
+	ds.cmd = NewZFSCmdMock(out, "", nil)
+
+	size, err := ds.EstimateSendSize(context.Background(), "two", "one", []string{"-w"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if size != 4096 {
+		t.Errorf("unexpected size: %d - expected: 4096", size)
+	}
 }
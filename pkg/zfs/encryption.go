@@ -0,0 +1,55 @@
+package zfs
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// Secret is a string which is masked in the log and in the json output - like a passphrase
+type Secret string
+
+const maskedSecret = "******"
+
+func (Secret) String() string {
+	return maskedSecret
+}
+
+func (Secret) GoString() string {
+	return `"` + maskedSecret + `"`
+}
+
+func (Secret) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + maskedSecret + `"`), nil
+}
+
+// IsEncrypted checks if the encryption is enabled for this dataset
+func (self *Dataset) IsEncrypted() bool {
+	return self.Encryption != ""
+}
+
+// LoadKey loads the encryption key for this dataset.
+//
+// The passphrase is passed per stdin - it's only necessary for 'keylocation=prompt'.
+// With an empty passphrase, the key is loaded from the configured key location.
+func (self *Dataset) LoadKey(ctx context.Context, passphrase Secret) error {
+	log.Debugf("load key for dataset: %s (key location: %s)", self.Name, self.KeyLocation)
+	var stdin io.Reader
+	if len(passphrase) > 0 {
+		stdin = strings.NewReader(string(passphrase) + "\n")
+	}
+
+	stderr, err := self.cmd.ExecPipe(ctx, stdin, ioutil.Discard, "load-key", self.Name)
+	log.Tracef("load key stderr: %s", stderr)
+	return err
+}
+
+// Mount mounts this dataset
+func (self *Dataset) Mount(ctx context.Context) error {
+	log.Debugf("mount dataset: %s", self.Name)
+	stdout, stderr, err := self.cmd.Exec(ctx, "mount", self.Name)
+	log.Tracef("mount dataset stdout: %s", stdout)
+	log.Tracef("mount dataset stderr: %s", stderr)
+	return err
+}
@@ -1,13 +1,97 @@
 package zfs
 
+import (
+	"context"
+	"strings"
+)
+
+// ErrorKind classifies a failed zfs command
+type ErrorKind string
+
+const (
+	ErrorUnknown          ErrorKind = ""
+	ErrorNotFound         ErrorKind = "not-found"
+	ErrorPermissionDenied ErrorKind = "permission-denied"
+	ErrorBusy             ErrorKind = "busy"
+	ErrorHasClones        ErrorKind = "has-clones"
+	ErrorHoldPresent      ErrorKind = "hold-present"
+	ErrorPoolSuspended    ErrorKind = "pool-suspended"
+	ErrorTimeout          ErrorKind = "timeout"
+)
+
+// errorPatterns maps messages from the zfs stderr output to the error kind.
+// the first match wins - the messages are compared in lower case.
+//
+// zfs reports the destroy of a held snapshot as busy - 'DestroySnapshot'
+// classifies it as 'ErrorHoldPresent' if the snapshot has holds.
+var errorPatterns = []struct {
+	pattern string
+	kind    ErrorKind
+}{
+	{"currently suspended", ErrorPoolSuspended},
+	{"permission denied", ErrorPermissionDenied},
+	{"operation not permitted", ErrorPermissionDenied},
+	{"incorrect key provided", ErrorPermissionDenied},
+	{"diff delegated permission is needed", ErrorPermissionDenied},
+	{"dependent clones", ErrorHasClones},
+	{"clones of previous snapshots exist", ErrorHasClones},
+	{"is busy", ErrorBusy},
+	{"does not exist", ErrorNotFound},
+	{"no such pool", ErrorNotFound},
+	{"no such tag", ErrorNotFound},
+	{"could not find any snapshots", ErrorNotFound},
+}
+
 type ExecZFSError struct {
-	err error
+	err  error
+	kind ErrorKind
+}
+
+// newExecZFSError classifies the error per the zfs error message
+func newExecZFSError(err error) ExecZFSError {
+	msg := strings.ToLower(err.Error())
+	for _, p := range errorPatterns {
+		if strings.Contains(msg, p.pattern) {
+			return ExecZFSError{err, p.kind}
+		}
+	}
+	return ExecZFSError{err, ErrorUnknown}
+}
+
+// newAbortedZFSError returns the error for a killed zfs command
+func newAbortedZFSError(err error, ctxErr error) ExecZFSError {
+	if ctxErr == context.DeadlineExceeded {
+		return ExecZFSError{err, ErrorTimeout}
+	}
+	return ExecZFSError{err, ErrorUnknown}
+}
+
+// NewExecZFSError returns the classified error for a failed zfs command - for 'ZFSCmd' implementations.
+// 'ctxErr' is the context error if the command was aborted, nil otherwise.
+func NewExecZFSError(err error, ctxErr error) ExecZFSError {
+	if ctxErr != nil {
+		return newAbortedZFSError(err, ctxErr)
+	}
+	return newExecZFSError(err)
 }
 
 func (self ExecZFSError) Error() string {
 	return self.err.Error()
 }
 
+// Kind returns the classification of this error
+func (self ExecZFSError) Kind() ErrorKind {
+	return self.kind
+}
+
+// KindOf returns the classification of the given error - 'ErrorUnknown' for non zfs errors
+func KindOf(err error) ErrorKind {
+	if e, ok := err.(ExecZFSError); ok {
+		return e.kind
+	}
+	return ErrorUnknown
+}
+
 type ExecutableNotFound struct {
 	err error
 }
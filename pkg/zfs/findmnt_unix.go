@@ -3,14 +3,46 @@
 package zfs
 
 import (
+	"context"
 	"fmt"
-	"os/exec"
+	"github.com/j-keck/zfs-snap-diff/pkg/transport"
 	"strings"
 )
 
+// newMountLookup returns the lookup per 'mount -l'
+func newMountLookup() mountLookup {
+	return findmntLookup{}
+}
+
+// findmntLookup resolves the mountpoints per 'mount -l'.
+// bind mounts and mount namespaces are not detected.
+type findmntLookup struct{}
+
+func (findmntLookup) MountPoint(datasetName string) (string, error) {
+	return findmnt(datasetName)
+}
+
+func (findmntLookup) BindMounts(datasetName string) []string {
+	return nil
+}
+
+func (findmntLookup) InOtherNamespace() bool {
+	return false
+}
+
+// isSnapshotMounted checks per directory listing if the snapshot is mounted
+func isSnapshotMounted(snap *Snapshot) (bool, error) {
+	return isSnapshotDirPopulated(snap)
+}
+
+// newMountCheck returns a function which checks per directory listing if a snapshot is mounted
+func newMountCheck() func(*Snapshot) (bool, error) {
+	return isSnapshotDirPopulated
+}
+
 func findmnt(name string) (string, error) {
 	log.Tracef("findmnt (unix) for '%s'", name)
-	out, err := exec.Command(
+	out, err := transport.Get().Command(context.Background(),
 		"mount", "-l", "-t", "zfs",
 	).Output()
 	if err != nil {
@@ -0,0 +1,124 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Hold - a user hold on a zfs snapshot
+type Hold struct {
+	Snapshot string    `json:"snapshot"`
+	Tag      string    `json:"tag"`
+	Created  time.Time `json:"created"`
+}
+
+// Holds are the holds from a zfs snapshot
+type Holds []Hold
+
+// Tags returns the tags from all holds
+func (self Holds) Tags() []string {
+	tags := make([]string, 0, len(self))
+	for _, h := range self {
+		tags = append(tags, h.Tag)
+	}
+	return tags
+}
+
+// ListHolds returns the holds from the given snapshot
+func (self *Dataset) ListHolds(ctx context.Context, name string) (Holds, error) {
+	if !strings.HasPrefix(name, self.Name) {
+		name = self.Name + "@" + name
+	}
+
+	stdout, _, err := self.cmd.Exec(ctx, "holds -H", name)
+	if err != nil {
+		return nil, err
+	}
+
+	holds := Holds{}
+	for _, line := range strings.Split(stdout, "\n") {
+		if hold, ok := parseHoldsLine(line); ok {
+			holds = append(holds, hold)
+		} else {
+			log.Tracef("ignore invalid formatted line: '%s'", line)
+		}
+	}
+	return holds, nil
+}
+
+// HoldSnapshot places a hold with the given tag on the snapshot
+func (self *Dataset) HoldSnapshot(ctx context.Context, name, tag string, flags []string) error {
+	if err := validateHoldTag(tag); err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(name, self.Name) {
+		name = self.Name + "@" + name
+	}
+
+	defer self.cache.invalidate(self.Name)
+	log.Debugf("hold snapshot: %s with tag: %s", name, tag)
+	args := append(flags, tag, name)
+	stdout, stderr, err := self.cmd.Exec(ctx, "hold", args...)
+	log.Tracef("hold snapshot stdout: %s", stdout)
+	log.Tracef("hold snapshot stderr: %s", stderr)
+	return err
+}
+
+// ReleaseSnapshot releases the hold with the given tag from the snapshot
+func (self *Dataset) ReleaseSnapshot(ctx context.Context, name, tag string, flags []string) error {
+	if err := validateHoldTag(tag); err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(name, self.Name) {
+		name = self.Name + "@" + name
+	}
+
+	defer self.cache.invalidate(self.Name)
+	log.Debugf("release snapshot: %s with tag: %s", name, tag)
+	args := append(flags, tag, name)
+	stdout, stderr, err := self.cmd.Exec(ctx, "release", args...)
+	log.Tracef("release snapshot stdout: %s", stdout)
+	log.Tracef("release snapshot stderr: %s", stderr)
+	return err
+}
+
+// validateHoldTag rejects empty tags and tags which would be parsed as an option
+func validateHoldTag(tag string) error {
+	if len(tag) == 0 {
+		return errors.New("hold-tag can't be empty")
+	}
+
+	if strings.HasPrefix(tag, "-") {
+		return fmt.Errorf("invalid hold-tag: '%s' - a tag can't start with a '-'", tag)
+	}
+	return nil
+}
+
+// parseHoldsLine parses a line from the 'zfs holds -H' output.
+//
+// format: <snapshot>\t<tag>\t<timestamp>
+func parseHoldsLine(line string) (Hold, bool) {
+	const n = 3
+	fields := strings.SplitN(line, "\t", n)
+	if len(fields) != n {
+		return Hold{}, false
+	}
+
+	// accept a numeric timestamp too - the format from 'zfs holds -p'
+	if secs, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+		return Hold{fields[0], fields[1], time.Unix(secs, 0)}, true
+	}
+
+	created, err := time.ParseInLocation("Mon Jan _2 15:04 2006", fields[2], time.Local)
+	if err != nil {
+		log.Warnf("invalid timestamp in 'zfs holds' output: '%s'", fields[2])
+		return Hold{}, false
+	}
+	return Hold{fields[0], fields[1], created}, true
+}
@@ -0,0 +1,134 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ImpactPreview lists what a destroy or rollback would remove - without changing anything
+type ImpactPreview struct {
+	// Destroyed are the full names of the snapshots and datasets which would be destroyed
+	Destroyed []string `json:"destroyed"`
+	// Clones are the dependent clones which would be destroyed (per '-R')
+	Clones []string `json:"clones"`
+	// Reclaim is the space in bytes which would be freed - only available for destroy
+	Reclaim uint64 `json:"reclaim"`
+}
+
+// PreviewDestroySnapshot runs 'zfs destroy -nvp' with the given flags
+// to list which snapshots and clones would be destroyed.
+func (self *Dataset) PreviewDestroySnapshot(ctx context.Context, name string, flags []string) (ImpactPreview, error) {
+	if !strings.HasPrefix(name, self.Name) {
+		name = self.Name + "@" + name
+	}
+
+	log.Debugf("preview destroy snapshot: %s", name)
+	args := append(append([]string{}, flags...), name)
+	stdout, stderr, err := self.cmd.Exec(ctx, "destroy -nvp", args...)
+	log.Tracef("preview destroy snapshot stderr: %s", stderr)
+	if err != nil {
+		return ImpactPreview{}, err
+	}
+
+	return parseDestroyDryRun(stdout)
+}
+
+// PreviewRollbackSnapshot lists the newer snapshots and their clones
+// which would be destroyed from a rollback with the given flags.
+//
+// Returns an error, if the rollback would be refused with the given flags
+// (newer snapshots without '-r', clones of them without '-R').
+func (self *Dataset) PreviewRollbackSnapshot(ctx context.Context, name string, flags []string) (ImpactPreview, error) {
+	name = strings.TrimPrefix(name, self.Name+"@")
+
+	log.Debugf("preview rollback snapshot: %s@%s", self.Name, name)
+
+	// bypass the cache - the preview must reflect the current state
+	snaps, err := self.scanSnapshots(ctx)
+	if err != nil {
+		return ImpactPreview{}, err
+	}
+
+	// the snapshots are sorted by creation, newest first - all before the target are newer
+	idx := -1
+	for i, s := range snaps {
+		if s.Name == name {
+			idx = i
+			break
+		}
+	}
+	if idx == -1 {
+		return ImpactPreview{}, fmt.Errorf("snapshot: '%s@%s' not found", self.Name, name)
+	}
+
+	preview := ImpactPreview{Destroyed: []string{}, Clones: []string{}}
+	newer := snaps[:idx]
+	if len(newer) == 0 {
+		return preview, nil
+	}
+
+	if !hasFlag(flags, "r") && !hasFlag(flags, "R") {
+		return preview, errors.New("more recent snapshots exist - use '-r' to destroy them")
+	}
+
+	for _, s := range newer {
+		preview.Clones = append(preview.Clones, s.Clones...)
+	}
+	if len(preview.Clones) > 0 && !hasFlag(flags, "R") {
+		return preview, fmt.Errorf("clones of more recent snapshots exist - use '-R' to destroy them: %s",
+			strings.Join(preview.Clones, ", "))
+	}
+
+	preview.Destroyed = append(preview.Destroyed, preview.Clones...)
+	for _, s := range newer {
+		preview.Destroyed = append(preview.Destroyed, s.FullName)
+	}
+	return preview, nil
+}
+
+// parseDestroyDryRun parses the output from 'zfs destroy -nvp'
+//
+//	destroy	tank/clone
+//	destroy	tank/fs@snap
+//	reclaim	2048
+func parseDestroyDryRun(out string) (ImpactPreview, error) {
+	preview := ImpactPreview{Destroyed: []string{}, Clones: []string{}}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, "\t", 2)
+		if len(fields) != 2 {
+			if len(line) > 0 {
+				log.Tracef("ignore invalid formatted line: '%s'", line)
+			}
+			continue
+		}
+
+		switch fields[0] {
+		case "destroy":
+			preview.Destroyed = append(preview.Destroyed, fields[1])
+			// only clones are datasets when a snapshot gets destroyed
+			if !strings.Contains(fields[1], "@") {
+				preview.Clones = append(preview.Clones, fields[1])
+			}
+		case "reclaim":
+			reclaim, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return preview, fmt.Errorf("unable to parse reclaim: '%s' - %v", fields[1], err)
+			}
+			preview.Reclaim = reclaim
+		}
+	}
+	return preview, nil
+}
+
+// hasFlag checks if the given single-letter flag is set - also in combined flags like '-rf'
+func hasFlag(flags []string, flag string) bool {
+	for _, f := range flags {
+		if strings.HasPrefix(f, "-") && strings.Contains(f[1:], flag) {
+			return true
+		}
+	}
+	return false
+}
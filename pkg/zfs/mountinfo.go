@@ -0,0 +1,245 @@
+package zfs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MountInfo is a entry from the mount table ('/proc/<pid>/mountinfo' on linux)
+type MountInfo struct {
+	ID         int
+	ParentID   int
+	Root       string
+	MountPoint string
+	FSType     string
+	Source     string
+}
+
+// IsBindMount returns true if a sub-directory of the filesystem is mounted.
+//
+// A bind mount of the whole filesystem can't be detected from a single
+// entry - see 'MountTable.BindMounts'.
+func (self *MountInfo) IsBindMount() bool {
+	return self.Root != "/"
+}
+
+// MountTable are the mounts in the order of the mountinfo file
+type MountTable []MountInfo
+
+// ParseMountInfo parses the mount table in the format from '/proc/<pid>/mountinfo'.
+//
+//	36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - zfs tank/fs rw,xattr
+//	(1)(2)(3)   (4)   (5)      (6)      (7)   (8)(9)  (10)     (11)
+//
+// see: https://www.kernel.org/doc/Documentation/filesystems/proc.txt
+func ParseMountInfo(r io.Reader) (MountTable, error) {
+	var table MountTable
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) == 0 {
+			continue
+		}
+
+		mount, err := parseMountInfoLine(line)
+		if err != nil {
+			return nil, err
+		}
+		table = append(table, mount)
+	}
+	return table, scanner.Err()
+}
+
+func parseMountInfoLine(line string) (MountInfo, error) {
+	fields := strings.Fields(line)
+
+	// the optional fields are terminated by a single hyphen
+	sep := -1
+	for i := 6; i < len(fields); i++ {
+		if fields[i] == "-" {
+			sep = i
+			break
+		}
+	}
+
+	if sep == -1 || len(fields) < sep+3 {
+		return MountInfo{}, fmt.Errorf("invalid formatted mountinfo line: '%s'", line)
+	}
+
+	id, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return MountInfo{}, fmt.Errorf("invalid mount id in line: '%s' - %v", line, err)
+	}
+
+	parentID, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return MountInfo{}, fmt.Errorf("invalid parent id in line: '%s' - %v", line, err)
+	}
+
+	// white-spaces in paths are octal escaped (like in the 'zfs diff' output)
+	return MountInfo{
+		ID:         id,
+		ParentID:   parentID,
+		Root:       unescapeZFSDiffPath(fields[3]),
+		MountPoint: unescapeZFSDiffPath(fields[4]),
+		FSType:     fields[sep+1],
+		Source:     unescapeZFSDiffPath(fields[sep+2]),
+	}, nil
+}
+
+// ZFS returns only the zfs mounts
+func (self MountTable) ZFS() MountTable {
+	var table MountTable
+	for _, m := range self {
+		if m.FSType == "zfs" {
+			table = append(table, m)
+		}
+	}
+	return table
+}
+
+// MountPointFor returns the mountpoint where the given dataset is mounted.
+//
+// Bind mounts are skipped - if the dataset is mounted multiple
+// times, the first mount is returned.
+func (self MountTable) MountPointFor(datasetName string) (string, bool) {
+	for _, m := range self {
+		if m.FSType == "zfs" && m.Source == datasetName && !m.IsBindMount() {
+			return m.MountPoint, true
+		}
+	}
+	return "", false
+}
+
+// BindMounts returns the additional mounts from the given dataset
+func (self MountTable) BindMounts(datasetName string) MountTable {
+	var table MountTable
+	first := true
+	for _, m := range self {
+		if m.FSType != "zfs" || m.Source != datasetName {
+			continue
+		}
+
+		if first && !m.IsBindMount() {
+			first = false
+			continue
+		}
+		table = append(table, m)
+	}
+	return table
+}
+
+// MountedSnapshots returns the mounted snapshots (the source contains a '@')
+func (self MountTable) MountedSnapshots() MountTable {
+	var table MountTable
+	for _, m := range self {
+		if m.FSType == "zfs" && strings.Contains(m.Source, "@") {
+			table = append(table, m)
+		}
+	}
+	return table
+}
+
+// IsMounted checks if the given dataset / snapshot is mounted
+func (self MountTable) IsMounted(name string) bool {
+	for _, m := range self {
+		if m.FSType == "zfs" && m.Source == name {
+			return true
+		}
+	}
+	return false
+}
+
+// BindMountPoints returns the mountpoints from the additional mounts of the given dataset
+func (self MountTable) BindMountPoints(datasetName string) []string {
+	var mountPoints []string
+	for _, m := range self.BindMounts(datasetName) {
+		mountPoints = append(mountPoints, m.MountPoint)
+	}
+	return mountPoints
+}
+
+// inOtherMountNamespace checks if the process runs in another mount namespace
+// as the process with the given pid (container, systemd 'ProtectSystem', ...).
+//
+// 'procDir' is the mountpoint from the proc filesystem - normally '/proc'.
+func inOtherMountNamespace(procDir, pid string) (bool, error) {
+	self, err := os.Readlink(filepath.Join(procDir, "self", "ns", "mnt"))
+	if err != nil {
+		return false, err
+	}
+
+	other, err := os.Readlink(filepath.Join(procDir, pid, "ns", "mnt"))
+	if err != nil {
+		return false, err
+	}
+	return self != other, nil
+}
+
+// mountLookup resolves the mounts from the datasets per scan
+type mountLookup interface {
+	// MountPoint returns the mountpoint from a dataset with a legacy mountpoint
+	MountPoint(datasetName string) (string, error)
+	// BindMounts returns the mountpoints from the bind mounts of a dataset
+	BindMounts(datasetName string) []string
+	// InOtherNamespace checks if the mounts are from another mount namespace as the init process.
+	// mounts which exists only in the other namespaces are not visible.
+	InOtherNamespace() bool
+}
+
+// mountTableLookup resolves the mounts per mount table - the mount table is loaded once
+type mountTableLookup struct {
+	load func() (MountTable, error)
+	// procDir is the proc filesystem for the namespace detection - empty to disable the detection
+	procDir string
+	table   MountTable
+	err     error
+	loaded  bool
+}
+
+func (self *mountTableLookup) mountTable() (MountTable, error) {
+	if !self.loaded {
+		self.loaded = true
+		self.table, self.err = self.load()
+	}
+	return self.table, self.err
+}
+
+func (self *mountTableLookup) MountPoint(datasetName string) (string, error) {
+	table, err := self.mountTable()
+	if err != nil {
+		return "", err
+	}
+
+	mountPoint, ok := table.MountPointFor(datasetName)
+	if !ok {
+		return "", fmt.Errorf("mountpoint for '%s' not found", datasetName)
+	}
+	return mountPoint, nil
+}
+
+func (self *mountTableLookup) BindMounts(datasetName string) []string {
+	table, err := self.mountTable()
+	if err != nil {
+		return nil
+	}
+	return table.BindMountPoints(datasetName)
+}
+
+func (self *mountTableLookup) InOtherNamespace() bool {
+	if len(self.procDir) == 0 {
+		return false
+	}
+
+	other, err := inOtherMountNamespace(self.procDir, "1")
+	if err != nil {
+		log.Debugf("unable to detect the mount namespace - %v", err)
+		return false
+	}
+	return other
+}
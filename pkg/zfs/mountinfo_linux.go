@@ -0,0 +1,51 @@
+package zfs
+
+import (
+	"github.com/j-keck/zfs-snap-diff/pkg/fs"
+	"github.com/j-keck/zfs-snap-diff/pkg/transport"
+)
+
+const mountInfoPath = "/proc/self/mountinfo"
+
+// loadMountTable reads the mount table from the current process.
+// with a transport wrapper, the mount table is read per the transport.
+func loadMountTable() (MountTable, error) {
+	fh, err := fs.Open(mountInfoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	return ParseMountInfo(fh)
+}
+
+// newMountLookup returns the lookup per '/proc/self/mountinfo'.
+//
+// the mount namespace is only detected for local commands - with a
+// transport wrapper, the mounts are from the wrapped environment.
+func newMountLookup() mountLookup {
+	lookup := &mountTableLookup{load: loadMountTable}
+	if transport.Get().IsLocal() {
+		lookup.procDir = "/proc"
+	}
+	return lookup
+}
+
+// isSnapshotMounted checks the mount table if the snapshot is mounted
+func isSnapshotMounted(snap *Snapshot) (bool, error) {
+	return newMountCheck()(snap)
+}
+
+// newMountCheck returns a function which checks the mount table if a snapshot is mounted.
+// this prevents the automount from zfs - which a directory listing would trigger.
+// the mount table is read once - at the first check.
+func newMountCheck() func(*Snapshot) (bool, error) {
+	lookup := &mountTableLookup{load: loadMountTable}
+	return func(snap *Snapshot) (bool, error) {
+		table, err := lookup.mountTable()
+		if err != nil {
+			return isSnapshotDirPopulated(snap)
+		}
+		return table.IsMounted(snap.FullName), nil
+	}
+}
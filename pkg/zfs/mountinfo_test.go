@@ -0,0 +1,123 @@
+package zfs
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func loadMountInfoFixture(t *testing.T) MountTable {
+	fh, err := os.Open("testdata/mountinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+
+	table, err := ParseMountInfo(fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return table
+}
+
+func TestParseMountInfo(t *testing.T) {
+	table := loadMountInfoFixture(t)
+
+	if len(table) != 10 {
+		t.Errorf("%d mounts parsed - expected 10", len(table))
+	}
+
+	if len(table.ZFS()) != 7 {
+		t.Errorf("%d zfs mounts found - expected 7", len(table.ZFS()))
+	}
+
+	// escaped white-spaces
+	if mp, ok := table.MountPointFor("rpool/home/user name"); !ok || mp != "/home/user name" {
+		t.Errorf("unexpected mountpoint: '%s'", mp)
+	}
+
+	if _, err := ParseMountInfo(strings.NewReader("22 1 0:21 / / rw\n")); err == nil {
+		t.Error("invalid line accepted")
+	}
+}
+
+func TestMountTableBindMounts(t *testing.T) {
+	table := loadMountInfoFixture(t)
+
+	if mp, ok := table.MountPointFor("tank/legacy"); !ok || mp != "/srv/legacy" {
+		t.Errorf("unexpected mountpoint: '%s' - expected: '/srv/legacy'", mp)
+	}
+
+	bindMounts := table.BindMounts("tank/legacy")
+	if len(bindMounts) != 2 {
+		t.Fatalf("%d bind mounts found - expected 2", len(bindMounts))
+	}
+
+	if !bindMounts[0].IsBindMount() || bindMounts[0].MountPoint != "/var/www/shared" {
+		t.Errorf("unexpected bind mount: %+v", bindMounts[0])
+	}
+
+	if _, ok := table.MountPointFor("tank/other"); ok {
+		t.Error("mountpoint for a not mounted dataset found")
+	}
+}
+
+func TestMountTableSnapshots(t *testing.T) {
+	table := loadMountInfoFixture(t)
+
+	snaps := table.MountedSnapshots()
+	if len(snaps) != 1 || snaps[0].MountPoint != "/home/.zfs/snapshot/daily-2020-03-09" {
+		t.Errorf("unexpected mounted snapshots: %+v", snaps)
+	}
+
+	if !table.IsMounted("rpool/home@daily-2020-03-09") {
+		t.Error("mounted snapshot not found")
+	}
+
+	if table.IsMounted("rpool/home@daily-2020-03-10") {
+		t.Error("not mounted snapshot found")
+	}
+}
+
+func TestMountTableLookup(t *testing.T) {
+	lookup := &mountTableLookup{load: func() (MountTable, error) { return loadMountInfoFixture(t), nil }}
+
+	if mp, err := lookup.MountPoint("tank/legacy"); err != nil || mp != "/srv/legacy" {
+		t.Errorf("unexpected mountpoint: '%s' - %v", mp, err)
+	}
+
+	bindMounts := lookup.BindMounts("tank/legacy")
+	if len(bindMounts) != 2 || bindMounts[0] != "/var/www/shared" || bindMounts[1] != "/mnt/legacy-copy" {
+		t.Errorf("unexpected bind mounts: %v", bindMounts)
+	}
+
+	if bindMounts := lookup.BindMounts("rpool/home"); len(bindMounts) != 0 {
+		t.Errorf("unexpected bind mounts: %v", bindMounts)
+	}
+
+	// namespace detection disabled
+	if lookup.InOtherNamespace() {
+		t.Error("other mount namespace detected without a proc filesystem")
+	}
+
+	lookup.procDir = "testdata/proc"
+	if !lookup.InOtherNamespace() {
+		t.Error("other mount namespace not detected")
+	}
+}
+
+func TestInOtherMountNamespace(t *testing.T) {
+	for pid, expected := range map[string]bool{"1": true, "42": false} {
+		other, err := inOtherMountNamespace("testdata/proc", pid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if other != expected {
+			t.Errorf("pid: %s - in other mount namespace: %v, expected: %v", pid, other, expected)
+		}
+	}
+
+	if _, err := inOtherMountNamespace("testdata/proc", "7"); err == nil {
+		t.Error("missing namespace accepted")
+	}
+}
@@ -0,0 +1,299 @@
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"github.com/j-keck/zfs-snap-diff/pkg/config"
+	"os"
+	"os/user"
+	"strings"
+)
+
+// Operations are the operations which are checked against the delegated permissions
+var Operations = []string{"snapshot", "destroy", "rename", "clone", "promote", "rollback", "mount", "load-key", "diff", "hold", "release", "bookmark", "send", "receive"}
+
+// operationPermissions are the delegated permissions which an operation needs (see 'zfs allow')
+var operationPermissions = map[string][]string{
+	"snapshot": {"snapshot", "mount"},
+	"destroy":  {"destroy", "mount"},
+	"rename":   {"rename", "create", "mount"},
+	"clone":    {"clone", "create", "mount"},
+	"promote":  {"promote", "mount"},
+	"rollback": {"rollback", "mount"},
+	"mount":    {"mount"},
+	"load-key": {"load-key", "mount"},
+	"diff":     {"diff"},
+	"hold":     {"hold"},
+	"release":  {"release"},
+	"bookmark": {"bookmark"},
+	"send":     {"send"},
+	"receive":  {"receive", "create", "mount"},
+}
+
+// SetPropertyOperation returns the operation name to set the given property.
+//
+// 'zfs allow' delegates native properties per property name
+// and all user properties per the 'userprop' permission.
+func SetPropertyOperation(name string) string {
+	if isUserProperty(name) {
+		return "set:userprop"
+	}
+	return "set:" + name
+}
+
+// allOperations returns the 'Operations' and the operations to set the editable properties
+func allOperations() []string {
+	ops := append([]string{}, Operations...)
+	for _, p := range EditableProperties {
+		ops = append(ops, SetPropertyOperation(p))
+	}
+	return append(ops, "set:userprop")
+}
+
+// permissionsFor returns the delegated permissions which the operation needs
+func permissionsFor(op string) []string {
+	if strings.HasPrefix(op, "set:") {
+		return []string{strings.TrimPrefix(op, "set:")}
+	}
+	return operationPermissions[op]
+}
+
+// Principal is the user which executes the zfs commands
+type Principal struct {
+	User   string   `json:"user"`
+	Groups []string `json:"groups"`
+	// Root needs no delegated permissions
+	Root bool `json:"root"`
+}
+
+// CurrentPrincipal returns the user which executes the zfs commands.
+//
+// Escalated zfs commands (see 'ZFSConfig.EscalationFor') are not checked
+// against the delegated permissions from this user.
+func CurrentPrincipal() (Principal, error) {
+	if os.Geteuid() == 0 {
+		return Principal{User: "root", Root: true}, nil
+	}
+
+	u, err := user.Current()
+	if err != nil {
+		return Principal{}, err
+	}
+
+	p := Principal{User: u.Username}
+	gids, err := u.GroupIds()
+	if err != nil {
+		return p, err
+	}
+	for _, gid := range gids {
+		// 'zfs allow' prints the group id if the group has no name
+		name := gid
+		if g, err := user.LookupGroupId(gid); err == nil {
+			name = g.Name
+		}
+		p.Groups = append(p.Groups, name)
+	}
+	return p, nil
+}
+
+// Capability describes if an operation is permitted
+type Capability struct {
+	Permitted bool `json:"permitted"`
+	// Missing are the delegated permissions which are necessary for the operation
+	Missing []string `json:"missing,omitempty"`
+}
+
+// Capabilities are the capabilities per operation
+type Capabilities map[string]Capability
+
+// Check returns an error, classified as 'ErrorPermissionDenied', if the operation is not permitted
+func (self Capabilities) Check(op, datasetName string) error {
+	if c, ok := self[op]; ok && !c.Permitted {
+		err := fmt.Errorf("operation '%s' not permitted on dataset: %s - %s", op, datasetName, c)
+		return ExecZFSError{err, ErrorPermissionDenied}
+	}
+	return nil
+}
+
+// Capabilities computes the permitted operations for the given principal
+// from the delegated permissions of this dataset.
+//
+// Escalated operations are always permitted - the escalation command
+// is expected to run with the necessary privileges.
+func (self *Dataset) Capabilities(ctx context.Context, principal Principal) (Capabilities, error) {
+	if principal.Root || allEscalated() {
+		return capabilitiesFor(func(string) bool { return true }), nil
+	}
+
+	stdout, _, err := self.cmd.Exec(ctx, "allow", self.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	granted := parseAllow(stdout).grantedFor(self.Name, principal)
+	return capabilitiesFor(func(perm string) bool { return granted[perm] }), nil
+}
+
+// allEscalated checks if all operations are executed per the escalation command
+func allEscalated() bool {
+	for _, op := range allOperations() {
+		if !isEscalated(op) {
+			return false
+		}
+	}
+	return true
+}
+
+// isEscalated checks if the operation is executed per the escalation command.
+// the operation names are the names of the zfs sub-commands - "set:<property>" for 'zfs set'.
+func isEscalated(op string) bool {
+	subCmd := strings.SplitN(op, ":", 2)[0]
+	return len(config.Get.ZFS.EscalationFor(subCmd)) > 0
+}
+
+func capabilitiesFor(granted func(string) bool) Capabilities {
+	caps := make(Capabilities)
+	for _, op := range allOperations() {
+		c := Capability{Permitted: true}
+		if isEscalated(op) {
+			caps[op] = c
+			continue
+		}
+
+		for _, perm := range permissionsFor(op) {
+			if !granted(perm) {
+				c.Permitted = false
+				c.Missing = append(c.Missing, perm)
+			}
+		}
+		caps[op] = c
+	}
+	return caps
+}
+
+// allowSection are the permissions from a 'Permissions on <dataset>' block
+type allowSection struct {
+	dataset string
+	sets    map[string][]string
+	// entries per scope: "local", "descendent" or "local+descendent"
+	entries map[string][]allowEntry
+}
+
+type allowEntry struct {
+	// kind is one of: user, group, everyone
+	kind  string
+	name  string
+	perms []string
+}
+
+type allowSections []allowSection
+
+// parseAllow parses the output from 'zfs allow <dataset>'
+//
+//	---- Permissions on tank/fs1 ----------------------------------------
+//	Permission sets:
+//		@backup hold,send
+//	Local+Descendent permissions:
+//		user alice @backup,mount,snapshot
+//		group staff diff
+//		everyone hold
+func parseAllow(out string) allowSections {
+	var sections allowSections
+	var scope string
+	for _, line := range strings.Split(out, "\n") {
+		switch {
+		case strings.HasPrefix(line, "---- Permissions on "):
+			name := strings.Fields(strings.TrimPrefix(line, "---- Permissions on "))[0]
+			sections = append(sections, allowSection{name, make(map[string][]string), make(map[string][]allowEntry)})
+			scope = ""
+		case strings.HasSuffix(line, ":") && !strings.HasPrefix(line, "\t"):
+			scope = strings.ToLower(strings.TrimSuffix(strings.TrimSuffix(line, ":"), " permissions"))
+		case strings.HasPrefix(line, "\t") && len(sections) > 0:
+			section := &sections[len(sections)-1]
+			fields := strings.Fields(line)
+			switch {
+			case scope == "permission sets" && len(fields) == 2:
+				section.sets[fields[0]] = strings.Split(fields[1], ",")
+			case len(fields) == 3 && (fields[0] == "user" || fields[0] == "group"):
+				section.entries[scope] = append(section.entries[scope],
+					allowEntry{fields[0], fields[1], strings.Split(fields[2], ",")})
+			case len(fields) == 2 && fields[0] == "everyone":
+				section.entries[scope] = append(section.entries[scope],
+					allowEntry{fields[0], "", strings.Split(fields[1], ",")})
+			default:
+				log.Tracef("ignore 'zfs allow' line: '%s'", line)
+			}
+		}
+	}
+	return sections
+}
+
+// grantedFor returns the permissions which are granted to the principal on the given dataset
+func (self allowSections) grantedFor(datasetName string, principal Principal) map[string]bool {
+	// permission sets are inherited from the parent datasets
+	sets := make(map[string][]string)
+	for i := len(self) - 1; i >= 0; i-- {
+		for name, perms := range self[i].sets {
+			sets[name] = perms
+		}
+	}
+
+	granted := make(map[string]bool)
+	var grant func(perms []string, depth int)
+	grant = func(perms []string, depth int) {
+		for _, perm := range perms {
+			if strings.HasPrefix(perm, "@") {
+				if depth < 10 {
+					grant(sets[perm], depth+1)
+				}
+				continue
+			}
+			granted[perm] = true
+		}
+	}
+
+	for _, section := range self {
+		var scopes []string
+		switch {
+		case section.dataset == datasetName:
+			scopes = []string{"local", "local+descendent"}
+		case strings.HasPrefix(datasetName, section.dataset+"/"):
+			scopes = []string{"descendent", "local+descendent"}
+		default:
+			continue
+		}
+
+		for _, scope := range scopes {
+			for _, e := range section.entries[scope] {
+				if principal.matches(e) {
+					grant(e.perms, 0)
+				}
+			}
+		}
+	}
+	return granted
+}
+
+func (self Principal) matches(e allowEntry) bool {
+	switch e.kind {
+	case "everyone":
+		return true
+	case "user":
+		return e.name == self.User
+	case "group":
+		for _, g := range self.Groups {
+			if g == e.name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// String returns a description of the missing permissions for error messages
+func (self Capability) String() string {
+	if self.Permitted {
+		return "permitted"
+	}
+	return fmt.Sprintf("missing delegated permission(s): %s", strings.Join(self.Missing, ", "))
+}
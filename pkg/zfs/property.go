@@ -0,0 +1,122 @@
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// EditableProperties are the native properties which can be changed per 'SetProperty'.
+// user properties (names with a ':') are always editable.
+var EditableProperties = []string{"snapdir", "compression", "quota", "refquota", "reservation", "atime"}
+
+// Property is a zfs property from a dataset
+type Property struct {
+	Name string `json:"name"`
+	// Value is a uint64 for numeric values, a string otherwise
+	Value    interface{} `json:"value"`
+	RawValue string      `json:"rawValue"`
+	// Source is one of: local, inherited, default, temporary, received, none
+	Source        string `json:"source"`
+	InheritedFrom string `json:"inheritedFrom,omitempty"`
+	Editable      bool   `json:"editable"`
+}
+
+// Properties are the properties from a dataset
+type Properties []Property
+
+// Get returns the property with the given name
+func (self Properties) Get(name string) (Property, bool) {
+	for _, p := range self {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Property{}, false
+}
+
+// IsEditableProperty checks if the property can be changed per 'SetProperty'
+func IsEditableProperty(name string) bool {
+	if isUserProperty(name) {
+		return true
+	}
+
+	for _, p := range EditableProperties {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isUserProperty(name string) bool {
+	return strings.Contains(name, ":")
+}
+
+// GetProperties returns all properties from this dataset
+func (self *Dataset) GetProperties(ctx context.Context) (Properties, error) {
+	stdout, _, err := self.cmd.Exec(ctx, "get -Hp -o property,value,source all", self.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	props := Properties{}
+	for _, line := range strings.Split(stdout, "\n") {
+		if p, ok := parsePropertyLine(line); ok {
+			props = append(props, p)
+		} else if len(line) > 0 {
+			log.Tracef("ignore invalid formatted line: '%s'", line)
+		}
+	}
+	return props, nil
+}
+
+// SetProperty sets the property to the given value.
+//
+// Only properties from 'EditableProperties' and user properties are accepted.
+func (self *Dataset) SetProperty(ctx context.Context, name, value string) error {
+	if !IsEditableProperty(name) {
+		return fmt.Errorf("property: '%s' is not editable", name)
+	}
+
+	if strings.ContainsAny(name, "= \t\n") || strings.ContainsAny(value, "\t\n") {
+		return fmt.Errorf("invalid property: '%s=%s'", name, value)
+	}
+
+	log.Debugf("set property: %s=%s on dataset: %s", name, value, self.Name)
+	stdout, stderr, err := self.cmd.Exec(ctx, "set", name+"="+value, self.Name)
+	log.Tracef("set property stdout: %s", stdout)
+	log.Tracef("set property stderr: %s", stderr)
+	return err
+}
+
+// parsePropertyLine parses a line from the 'zfs get -Hp -o property,value,source' output
+func parsePropertyLine(line string) (Property, bool) {
+	const n = 3
+	fields := strings.SplitN(line, "\t", n)
+	if len(fields) != n {
+		return Property{}, false
+	}
+
+	p := Property{Name: fields[0], RawValue: fields[1], Editable: IsEditableProperty(fields[0])}
+
+	// user properties are always strings
+	p.Value = fields[1]
+	if !isUserProperty(p.Name) {
+		if num, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			p.Value = num
+		}
+	}
+
+	switch source := fields[2]; {
+	case source == "-":
+		p.Source = "none"
+	case strings.HasPrefix(source, "inherited from "):
+		p.Source = "inherited"
+		p.InheritedFrom = strings.TrimPrefix(source, "inherited from ")
+	default:
+		p.Source = source
+	}
+	return p, true
+}
@@ -0,0 +1,76 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ReceiveSnapshot receives a 'zfs send' stream per 'zfs receive' into a new dataset.
+//
+// The target is validated per 'CheckReceiveTarget'. The received dataset is
+// never mounted and the mountpoint from the stream is ignored (see 'receiveArgs').
+func (self *ZFS) ReceiveSnapshot(ctx context.Context, target string, flags []string, r io.Reader) error {
+	if err := self.CheckReceiveTarget(ctx, target); err != nil {
+		return err
+	}
+
+	// the target can be a dataset or a snapshot name
+	datasetName := strings.SplitN(target, "@", 2)[0]
+	defer self.cache.invalidate(datasetName)
+	log.Debugf("receive stream into: %s", target)
+	args := receiveArgs(flags, target)
+	stderr, err := self.cmd.ExecPipe(ctx, r, nil, "receive", args...)
+	log.Tracef("receive stderr: %s", stderr)
+	return err
+}
+
+// CheckReceiveTarget checks if a stream can be received into the given target.
+//
+// The target must live below one of the dataset trees from this zfs handler
+// and the dataset must not exist - existing datasets are never overwritten.
+func (self *ZFS) CheckReceiveTarget(ctx context.Context, target string) error {
+	if len(target) == 0 {
+		return errors.New("target dataset-name can't be empty")
+	}
+
+	// the target can be a dataset or a snapshot name
+	datasetName := strings.SplitN(target, "@", 2)[0]
+	isBelow := false
+	for _, name := range self.names {
+		if strings.HasPrefix(datasetName, name+"/") {
+			isBelow = true
+			break
+		}
+	}
+	if !isBelow {
+		return fmt.Errorf("target: '%s' does not live below the dataset(s): '%s'",
+			target, strings.Join(self.names, "', '"))
+	}
+
+	_, _, err := self.cmd.Exec(ctx, "list -H -o name", datasetName)
+	if err == nil {
+		return fmt.Errorf("target: '%s' already exists - a stream can only be received into a new dataset", datasetName)
+	}
+	if KindOf(err) != ErrorNotFound {
+		return err
+	}
+	return nil
+}
+
+// receiveArgs returns the arguments for 'zfs receive'.
+//
+// '-u' and '-x mountpoint' are always added: the stream is untrusted and
+// 'zfs receive' runs often escalated - a mountpoint from the stream could
+// mount the dataset over an arbitrary path.
+func receiveArgs(flags []string, target string) []string {
+	args := []string{"-u", "-x", "mountpoint"}
+	for _, flag := range flags {
+		if flag != "-u" {
+			args = append(args, flag)
+		}
+	}
+	return append(args, target)
+}
@@ -0,0 +1,79 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// SendSnapshot streams the given snapshot per 'zfs send' into the writer.
+//
+// If 'incrementalFrom' is given, a incremental stream is generated. Per default ('-i')
+// from the given snapshot - with the flag '-I' including all intermediary snapshots.
+func (self *Dataset) SendSnapshot(ctx context.Context, name, incrementalFrom string, flags []string, w io.Writer) error {
+	args, err := self.sendArgs(name, incrementalFrom, flags)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("send snapshot: %s", strings.Join(args, " "))
+	stderr, err := self.cmd.ExecPipe(ctx, nil, w, "send", args...)
+	log.Tracef("send snapshot stderr: %s", stderr)
+	return err
+}
+
+// EstimateSendSize returns the estimated stream size from 'zfs send' for the given snapshot
+func (self *Dataset) EstimateSendSize(ctx context.Context, name, incrementalFrom string, flags []string) (uint64, error) {
+	args, err := self.sendArgs(name, incrementalFrom, flags)
+	if err != nil {
+		return 0, err
+	}
+
+	stdout, _, err := self.cmd.Exec(ctx, "send -nP", args...)
+	if err != nil {
+		return 0, err
+	}
+
+	// the output ends with a line: 'size\t<BYTES>'
+	for _, line := range strings.Split(stdout, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) == 2 && fields[0] == "size" {
+			return strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("no size in 'zfs send' dry-run output found")
+}
+
+func (self *Dataset) sendArgs(name, incrementalFrom string, flags []string) ([]string, error) {
+	if len(name) == 0 {
+		return nil, errors.New("snapshot-name can't be empty")
+	}
+
+	if !strings.HasPrefix(name, self.Name) {
+		name = self.Name + "@" + name
+	}
+
+	var args []string
+	incrementalFlag := "-i"
+	for _, flag := range flags {
+		if flag == "-I" {
+			incrementalFlag = flag
+		} else {
+			args = append(args, flag)
+		}
+	}
+
+	if len(incrementalFrom) > 0 {
+		// '-i' accepts also bookmarks
+		if !strings.HasPrefix(incrementalFrom, self.Name) &&
+			!strings.HasPrefix(incrementalFrom, "#") && !strings.HasPrefix(incrementalFrom, "@") {
+			incrementalFrom = "@" + incrementalFrom
+		}
+		args = append(args, incrementalFlag, incrementalFrom)
+	}
+
+	return append(args, name), nil
+}
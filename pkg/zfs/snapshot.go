@@ -2,25 +2,61 @@ package zfs
 
 import (
 	"github.com/j-keck/zfs-snap-diff/pkg/fs"
+	"github.com/j-keck/zfs-snap-diff/pkg/transport"
 	"os"
 	"time"
 )
 
 // Snapshot - zfs snapshot
+//
+// The space accounting values are in bytes: 'Used' is the space which is freed
+// when only this snapshot is destroyed, 'Written' the space written since the
+// previous snapshot. The 'GUID' is a string, because a 64 bit number
+// can't be represented in javascript.
 type Snapshot struct {
 	Name       string       `json:"name"`
 	FullName   string       `json:"fullName"`
 	Created    time.Time    `json:"created"`
+	Holds      int          `json:"holds"`
+	Used       uint64       `json:"used"`
+	Referenced uint64       `json:"referenced"`
+	Written    uint64       `json:"written"`
+	CreateTXG  uint64       `json:"createTxg"`
+	GUID       string       `json:"guid"`
+	Clones     []string     `json:"clones"`
 	MountPoint fs.DirHandle `json:"mountPoint"`
 }
 
 // Check if the snaphot is mounted
 func (s *Snapshot) IsMounted() (bool, error) {
 	log.Tracef("check if snapshot: %s is mounted", s.Name)
+	return isSnapshotMounted(s)
+}
+
+// MountCheck checks if a snapshot is mounted - see 'NewMountCheck'
+type MountCheck func(*Snapshot) (bool, error)
+
+// NewMountCheck returns a check if a snapshot is mounted for scans over many snapshots.
+//
+// On linux the mount table is read once per check - use a new check per scan.
+func NewMountCheck() MountCheck {
+	return newMountCheck()
+}
 
+// isSnapshotDirPopulated checks per directory listing if the snapshot is mounted
+func isSnapshotDirPopulated(s *Snapshot) (bool, error) {
 	// to check if the snapshot is mounted, list
 	// the directory content. use 'File.Readdirnames'
 	// for the directrory listing, because it has less overhead
+	if !transport.Get().IsLocal() {
+		// the mountpoint is not visible local
+		ls, err := s.MountPoint.Ls()
+		if err != nil {
+			return false, err
+		}
+		return len(ls) > 0, nil
+	}
+
 	fh, err := os.Open(s.MountPoint.Path)
 	if err != nil {
 		return false, err
@@ -0,0 +1,117 @@
+package zfs
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SnapshotCache caches the snapshot inventory per dataset.
+//
+// Entries expire after the configured TTL and are invalidated
+// after snapshot modifications from this tool.
+type SnapshotCache struct {
+	mutex   sync.Mutex
+	ttl     time.Duration
+	entries map[string]snapshotCacheEntry
+}
+
+type snapshotCacheEntry struct {
+	Scanned   time.Time `json:"scanned"`
+	Snapshots Snapshots `json:"snapshots"`
+}
+
+// NewSnapshotCache creates a cache where the entries expires after the given ttl.
+// A ttl of zero disables the cache.
+func NewSnapshotCache(ttl time.Duration) *SnapshotCache {
+	return &SnapshotCache{ttl: ttl, entries: make(map[string]snapshotCacheEntry)}
+}
+
+// get returns the cached snapshots for the given dataset if they are not expired
+func (self *SnapshotCache) get(datasetName string) (Snapshots, bool) {
+	if self == nil || self.ttl <= 0 {
+		return nil, false
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	entry, ok := self.entries[datasetName]
+	if !ok || time.Since(entry.Scanned) > self.ttl {
+		return nil, false
+	}
+
+	log.Tracef("snapshot cache hit for dataset: %s", datasetName)
+	return append(Snapshots{}, entry.Snapshots...), true
+}
+
+func (self *SnapshotCache) put(datasetName string, snaps Snapshots) {
+	if self == nil || self.ttl <= 0 {
+		return
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.entries[datasetName] = snapshotCacheEntry{time.Now(), append(Snapshots{}, snaps...)}
+}
+
+// invalidate drops the entries from the given dataset and all descendent datasets
+func (self *SnapshotCache) invalidate(datasetName string) {
+	if self == nil {
+		return
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	for name := range self.entries {
+		if name == datasetName || strings.HasPrefix(name, datasetName+"/") {
+			log.Tracef("invalidate snapshot cache for dataset: %s", name)
+			delete(self.entries, name)
+		}
+	}
+}
+
+// invalidateWithFlags invalidates the whole cache if the flags can
+// affect other dataset trees (like '-R' for dependent clones)
+func (self *SnapshotCache) invalidateWithFlags(datasetName string, flags []string) {
+	for _, flag := range flags {
+		if strings.Contains(flag, "R") {
+			self.InvalidateAll()
+			return
+		}
+	}
+	self.invalidate(datasetName)
+}
+
+// InvalidateAll drops all entries
+func (self *SnapshotCache) InvalidateAll() {
+	if self == nil {
+		return
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	log.Trace("invalidate snapshot cache")
+	self.entries = make(map[string]snapshotCacheEntry)
+}
+
+// MarshalJSON encodes the cache entries - to persist the cache between invocations
+func (self *SnapshotCache) MarshalJSON() ([]byte, error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return json.Marshal(self.entries)
+}
+
+// UnmarshalJSON replaces the cache entries with the decoded entries
+func (self *SnapshotCache) UnmarshalJSON(b []byte) error {
+	entries := make(map[string]snapshotCacheEntry)
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return err
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	self.entries = entries
+	return nil
+}
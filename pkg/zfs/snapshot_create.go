@@ -0,0 +1,81 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CreateSnapshots creates the snapshot with the given name atomically across the given datasets.
+//
+// Use the flag '-r' to create the snapshots recursive for all descendent datasets.
+// The given user properties are set at creation.
+func (self *ZFS) CreateSnapshots(ctx context.Context, datasetNames []string, name string, flags []string, props map[string]string) ([]string, error) {
+	if len(name) == 0 {
+		return nil, errors.New("snapshot-name can't be empty")
+	}
+
+	if len(datasetNames) == 0 {
+		return nil, errors.New("no dataset given")
+	}
+
+	var names []string
+	for _, datasetName := range datasetNames {
+		ds, err := self.FindDatasetByName(datasetName)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, ds.Name+"@"+name)
+		defer self.cache.invalidate(ds.Name)
+	}
+
+	return names, createSnapshots(ctx, self.cmd, names, flags, props)
+}
+
+// createSnapshots creates all snapshots in one 'zfs snapshot' call - zfs creates them atomically
+func createSnapshots(ctx context.Context, cmd ZFSCmd, names []string, flags []string, props map[string]string) error {
+	// validate the names before 'zfs' runs - the error messages from zfs are not very helpful
+	for _, name := range names {
+		if len(name) > MaxNameLength {
+			return fmt.Errorf("snapshot-name '%s' is too long - max. %d characters", name, MaxNameLength)
+		}
+
+		if err := ValidateSnapshotName(name[strings.LastIndex(name, "@")+1:]); err != nil {
+			return fmt.Errorf("invalid snapshot-name - %v", err)
+		}
+	}
+
+	// sort the properties to get a stable command line
+	var keys []string
+	for k := range props {
+		if !strings.Contains(k, ":") {
+			return fmt.Errorf("invalid user property: '%s' - user properties must contain a ':'", k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	args := append([]string{}, flags...)
+	for _, k := range keys {
+		args = append(args, "-o", k+"="+props[k])
+	}
+	args = append(args, names...)
+
+	log.Debugf("create snapshot(s): %s", strings.Join(names, ", "))
+	stdout, stderr, err := cmd.Exec(ctx, "snapshot", args...)
+	log.Tracef("create snapshot stdout: %s", stdout)
+	log.Tracef("create snapshot stderr: %s", stderr)
+	if err != nil {
+		return err
+	}
+
+	// persist the '%{counter}' values from the created snapshot names
+	for _, name := range names {
+		if err := snapshotNameCounter.created(name); err != nil {
+			log.Warnf("unable to save the snapshot name counter for: %s - %v", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"github.com/j-keck/zfs-snap-diff/pkg/fs"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ChangeType describes how a filesystem entry was changed between two snapshots
+type ChangeType string
+
+const (
+	Modified ChangeType = "modified"
+	Added    ChangeType = "added"
+	Removed  ChangeType = "removed"
+	Renamed  ChangeType = "renamed"
+)
+
+// FileChange is a single entry from a snapshot diff
+type FileChange struct {
+	Change ChangeType `json:"change"`
+	Kind   fs.Kind    `json:"kind"`
+	Path   string     `json:"path"`
+	// NewPath is only set for renamed entries
+	NewPath string    `json:"newPath,omitempty"`
+	CTime   time.Time `json:"ctime"`
+}
+
+// FileChanges are the changes between two snapshots
+type FileChanges []FileChange
+
+// DiffSnapshots returns the changes between the snapshot 'from' and the snapshot 'to'.
+//
+// If 'to' is empty, the snapshot 'from' is compared against the current filesystem state.
+//
+// HINT: the process uid needs 'zfs allow -u <USER> diff <ZFS_NAME>'
+func (self *Dataset) DiffSnapshots(ctx context.Context, from, to string) (FileChanges, error) {
+	if len(from) == 0 {
+		return nil, fmt.Errorf("snapshot-name can't be empty")
+	}
+
+	if !strings.HasPrefix(from, self.Name) {
+		from = self.Name + "@" + from
+	}
+
+	args := []string{from}
+	if len(to) > 0 {
+		if !strings.HasPrefix(to, self.Name) {
+			to = self.Name + "@" + to
+		}
+		args = append(args, to)
+	}
+
+	log.Debugf("diff snapshots: %s", strings.Join(args, " -> "))
+	stdout, stderr, err := self.cmd.Exec(ctx, "diff -FHt", args...)
+	if err != nil {
+		log.Tracef("diff snapshots stderr: %s", stderr)
+		return nil, err
+	}
+
+	changes := FileChanges{}
+	for _, line := range strings.Split(stdout, "\n") {
+		if change, ok := parseZFSDiffLine(line); ok {
+			changes = append(changes, change)
+		} else {
+			log.Tracef("ignore invalid formatted line: '%s'", line)
+		}
+	}
+	return changes, nil
+}
+
+// parseZFSDiffLine parses a line from the 'zfs diff -FHt' output.
+//
+// format: <ctime>\t<change>\t<file-type>\t<path>[\t<new-path>]
+func parseZFSDiffLine(line string) (FileChange, bool) {
+	fields := strings.Split(line, "\t")
+	if len(fields) < 4 || len(fields) > 5 {
+		return FileChange{}, false
+	}
+
+	ctime, ok := parseZFSDiffTimestamp(fields[0])
+	if !ok {
+		log.Warnf("invalid timestamp in 'zfs diff' output: '%s'", fields[0])
+		return FileChange{}, false
+	}
+
+	var change ChangeType
+	switch fields[1] {
+	case "M":
+		change = Modified
+	case "+":
+		change = Added
+	case "-":
+		change = Removed
+	case "R":
+		change = Renamed
+	default:
+		log.Warnf("unknown change type in 'zfs diff' output: '%s'", fields[1])
+		return FileChange{}, false
+	}
+
+	var kind fs.Kind
+	switch fields[2] {
+	case "/":
+		kind = fs.DIR
+	case "@":
+		kind = fs.LINK
+	case "|":
+		kind = fs.PIPE
+	case "=":
+		kind = fs.SOCKET
+	case "B", "C":
+		kind = fs.DEV
+	default:
+		// 'F' for regular files - and the solaris only
+		// doors ('>') and event ports ('P')
+		kind = fs.FILE
+	}
+
+	fc := FileChange{Change: change, Kind: kind, Path: unescapeZFSDiffPath(fields[3]), CTime: ctime}
+	if len(fields) == 5 {
+		if change != Renamed {
+			return FileChange{}, false
+		}
+		fc.NewPath = unescapeZFSDiffPath(fields[4])
+	}
+	return fc, true
+}
+
+// parseZFSDiffTimestamp parses the 'seconds.nanoseconds' timestamp from 'zfs diff -t'
+func parseZFSDiffTimestamp(s string) (time.Time, bool) {
+	fields := strings.SplitN(s, ".", 2)
+	secs, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var nsecs int64
+	if len(fields) == 2 {
+		if nsecs, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+			return time.Time{}, false
+		}
+	}
+	return time.Unix(secs, nsecs), true
+}
+
+// unescapeZFSDiffPath replaces the octal escape sequences ('\040' for a space, ...)
+// in the 'zfs diff' output with the real characters (see: https://www.illumos.org/issues/1912)
+func unescapeZFSDiffPath(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,303 @@
+package zfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/j-keck/zfs-snap-diff/pkg/fs"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxNameLength is the maximum length of a full dataset / snapshot name
+const MaxNameLength = 255
+
+// NameTemplateVars provides the values for the '%{name}' variables in a snapshot name template.
+//
+// The values are evaluated lazy - only when the variable is used in the template.
+type NameTemplateVars map[string]func() (string, error)
+
+// FormatSnapshotName creates a snapshot name from the given template.
+//
+// The format sequences are alike the `date` command
+// (the same as in the 'create snapshot' dialog from the webapp):
+//
+//	%d: day of month (e.g., 01)
+//	%m: month (01..12)
+//	%y: last two digits of year (00..99)
+//	%Y: year
+//	%F: full date; like %Y-%m-%d
+//	%H: hour (00..23)
+//	%I: hour (01..12)
+//	%M: minute (00..59)
+//	%S: second (00..60)
+//	%s: seconds since 1970-01-01 00:00:00 UTC
+//
+// Variables are referenced per '%{name}' - see 'Dataset.NameTemplateVars'.
+func FormatSnapshotName(template string, t time.Time, vars NameTemplateVars) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+		if c != '%' {
+			sb.WriteByte(c)
+			continue
+		}
+
+		if i+1 == len(template) {
+			return "", fmt.Errorf("invalid format: '%%' at the end of the template: '%s'", template)
+		}
+
+		i++
+		switch template[i] {
+		case 'd':
+			sb.WriteString(t.Format("02"))
+		case 'm':
+			sb.WriteString(t.Format("01"))
+		case 'y':
+			sb.WriteString(t.Format("06"))
+		case 'Y':
+			sb.WriteString(t.Format("2006"))
+		case 'F':
+			sb.WriteString(t.Format("2006-01-02"))
+		case 'H':
+			sb.WriteString(t.Format("15"))
+		case 'I':
+			sb.WriteString(t.Format("03"))
+		case 'M':
+			sb.WriteString(t.Format("04"))
+		case 'S':
+			sb.WriteString(t.Format("05"))
+		case 's':
+			sb.WriteString(strconv.FormatInt(t.Unix(), 10))
+		case '{':
+			end := strings.IndexByte(template[i:], '}')
+			if end == -1 {
+				return "", fmt.Errorf("unterminated variable in template: '%s'", template)
+			}
+
+			name := template[i+1 : i+end]
+			value, ok := vars[name]
+			if !ok {
+				return "", fmt.Errorf("unknown variable: '%%{%s}' in template: '%s'", name, template)
+			}
+
+			s, err := value()
+			if err != nil {
+				return "", fmt.Errorf("unable to evaluate variable: '%%{%s}' - %v", name, err)
+			}
+			sb.WriteString(s)
+			i += end
+		default:
+			return "", fmt.Errorf("invalid format: '%%%c' in template: '%s'", template[i], template)
+		}
+	}
+
+	name := sb.String()
+	return name, ValidateSnapshotName(name)
+}
+
+// ValidateSnapshotNameTemplate checks the template syntax without evaluating the variables
+func ValidateSnapshotNameTemplate(template string) error {
+	vars := NameTemplateVars{}
+	for name := range (&Dataset{}).NameTemplateVars() {
+		vars[name] = func() (string, error) { return "x", nil }
+	}
+
+	_, err := FormatSnapshotName(template, time.Now(), vars)
+	return err
+}
+
+// ValidateSnapshotName checks if the given name is a valid snapshot name
+// (see: https://wiki.openindiana.org/oi/ZFS+naming+conventions)
+func ValidateSnapshotName(name string) error {
+	if len(name) == 0 {
+		return fmt.Errorf("name can't be empty")
+	}
+
+	if len(name) > MaxNameLength {
+		return fmt.Errorf("name '%s' is too long - max. %d characters", name, MaxNameLength)
+	}
+
+	for _, c := range name {
+		if !isValidNameChar(c) {
+			return fmt.Errorf("invalid character '%c' in name: '%s'", c, name)
+		}
+	}
+	return nil
+}
+
+func isValidNameChar(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+		strings.ContainsRune("_-:. ", c)
+}
+
+// NameTemplateVars returns the variables for snapshot name templates:
+//
+//	%{dataset}:   dataset name - '/' replaced with '_'
+//	%{shortname}: last component from the dataset name
+//	%{user}:      name of the current user
+//	%{counter}:   per dataset monotonically increasing counter - the counter
+//	              is persisted when the snapshot was created (see 'createSnapshots')
+func (self *Dataset) NameTemplateVars() NameTemplateVars {
+	// replace invalid characters like the '/' in the dataset name
+	sanitize := func(s string) string {
+		return strings.Map(func(c rune) rune {
+			if isValidNameChar(c) {
+				return c
+			}
+			return '_'
+		}, s)
+	}
+
+	return NameTemplateVars{
+		"dataset": func() (string, error) {
+			return sanitize(self.Name), nil
+		},
+		"shortname": func() (string, error) {
+			return sanitize(self.Name[strings.LastIndex(self.Name, "/")+1:]), nil
+		},
+		"user": func() (string, error) {
+			if u, err := user.Current(); err == nil {
+				return sanitize(u.Username), nil
+			}
+
+			if name := os.Getenv("USER"); name != "" {
+				return sanitize(name), nil
+			}
+			return "", fmt.Errorf("unable to lookup the current user")
+		},
+		"counter": func() (string, error) {
+			n, err := snapshotNameCounter.peek(self.Name)
+			return strconv.Itoa(n), err
+		},
+	}
+}
+
+// SnapshotNameFromTemplate creates a snapshot name for this dataset from the given template
+func (self *Dataset) SnapshotNameFromTemplate(template string, t time.Time) (string, error) {
+	vars := self.NameTemplateVars()
+
+	// remember the counter value - it's persisted when the snapshot was created
+	counter := 0
+	peek := vars["counter"]
+	vars["counter"] = func() (string, error) {
+		s, err := peek()
+		if err == nil {
+			counter, _ = strconv.Atoi(s)
+		}
+		return s, err
+	}
+
+	name, err := FormatSnapshotName(template, t, vars)
+	if err == nil && counter > 0 {
+		snapshotNameCounter.reserve(self.Name, name, counter)
+	}
+	return name, err
+}
+
+// nameCounter persists the counters for the '%{counter}' variable per dataset.
+//
+// A counter value is only persisted after the snapshot with the
+// reserved name was created - a failed create does not burn a value.
+type nameCounter struct {
+	mutex   sync.Mutex
+	path    func() (string, error)
+	pending map[string]pendingCounter
+}
+
+// pendingCounter is a counter value which is used in the name from a not yet created snapshot
+type pendingCounter struct {
+	snapshotName string
+	value        int
+}
+
+var snapshotNameCounter = &nameCounter{path: func() (string, error) {
+	dir, err := fs.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir.Path, "snapshot-name-counters.json"), nil
+}}
+
+// peek returns the next counter value for the given dataset without persisting it
+func (self *nameCounter) peek(datasetName string) (int, error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	counters, _, err := self.load()
+	if err != nil {
+		return 0, err
+	}
+	return counters[datasetName] + 1, nil
+}
+
+// reserve remembers the counter value from the given snapshot name until the snapshot was created
+func (self *nameCounter) reserve(datasetName, snapshotName string, value int) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if self.pending == nil {
+		self.pending = make(map[string]pendingCounter)
+	}
+	self.pending[datasetName] = pendingCounter{snapshotName, value}
+}
+
+// created persists the reserved counter value if the snapshot was created with the reserved name
+func (self *nameCounter) created(fullName string) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	fields := strings.SplitN(fullName, "@", 2)
+	if len(fields) != 2 {
+		return nil
+	}
+
+	datasetName, snapshotName := fields[0], fields[1]
+	p, ok := self.pending[datasetName]
+	if !ok || p.snapshotName != snapshotName {
+		return nil
+	}
+	delete(self.pending, datasetName)
+
+	counters, path, err := self.load()
+	if err != nil {
+		return err
+	}
+
+	// the counter is monotonically increasing
+	if p.value <= counters[datasetName] {
+		return nil
+	}
+	counters[datasetName] = p.value
+
+	content, err := json.Marshal(counters)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("save snapshot name counter for dataset: %s: %d", datasetName, p.value)
+	return ioutil.WriteFile(path, content, 0640)
+}
+
+// load reads the persisted counters
+func (self *nameCounter) load() (map[string]int, string, error) {
+	path, err := self.path()
+	if err != nil {
+		return nil, "", err
+	}
+
+	counters := make(map[string]int)
+	if content, err := ioutil.ReadFile(path); err == nil {
+		if err := json.Unmarshal(content, &counters); err != nil {
+			return nil, path, fmt.Errorf("unable to parse the counter file: %s - %v", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, path, err
+	}
+	return counters, path, nil
+}
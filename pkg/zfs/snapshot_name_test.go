@@ -0,0 +1,97 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFormatSnapshotName(t *testing.T) {
+	ts := time.Date(2020, 3, 9, 14, 5, 7, 0, time.UTC)
+
+	name, err := FormatSnapshotName("zfs-snap-diff-%FT%H:%M", ts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "zfs-snap-diff-2020-03-09T14:05"; name != expected {
+		t.Errorf("unexpected name: '%s' - expected: '%s'", name, expected)
+	}
+
+	name, _ = FormatSnapshotName("%y%m%d-%I%S-%s", ts, nil)
+	if expected := "200309-0207-1583762707"; name != expected {
+		t.Errorf("unexpected name: '%s' - expected: '%s'", name, expected)
+	}
+
+	if _, err := FormatSnapshotName("snap-%Q", ts, nil); err == nil {
+		t.Error("invalid format sequence accepted")
+	}
+
+	if _, err := FormatSnapshotName("snap/%F", ts, nil); err == nil {
+		t.Error("invalid character accepted")
+	}
+
+	if _, err := FormatSnapshotName("snap %F", ts, nil); err != nil {
+		t.Errorf("space rejected - %v", err)
+	}
+}
+
+func TestSnapshotNameFromTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zsd-counter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defaultCounter := snapshotNameCounter
+	defer func() { snapshotNameCounter = defaultCounter }()
+	snapshotNameCounter = &nameCounter{path: func() (string, error) {
+		return filepath.Join(dir, "counters.json"), nil
+	}}
+
+	ds := Dataset{Name: "tank/home/user"}
+	ts := time.Date(2020, 3, 9, 14, 5, 7, 0, time.UTC)
+
+	name, err := ds.SnapshotNameFromTemplate("%{dataset}-%{shortname}-%F", ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "tank_home_user-user-2020-03-09"; name != expected {
+		t.Errorf("unexpected name: '%s' - expected: '%s'", name, expected)
+	}
+
+	// the counter is persisted after the snapshot was created - a failed create does not burn a value
+	ctx := context.Background()
+	for _, step := range []struct {
+		expected string
+		err      error
+	}{{"snap-1", errors.New("out of space")}, {"snap-1", nil}, {"snap-2", nil}} {
+		name, err := ds.SnapshotNameFromTemplate("snap-%{counter}", ts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != step.expected {
+			t.Errorf("unexpected name: '%s' - expected: '%s'", name, step.expected)
+		}
+
+		ds.cmd = NewZFSCmdMock("", "", step.err)
+		if _, err := ds.CreateSnapshot(ctx, name, nil, nil); err != step.err {
+			t.Errorf("unexpected create snapshot error: %v", err)
+		}
+	}
+
+	if _, err := ds.SnapshotNameFromTemplate("snap-%{unknown}", ts); err == nil {
+		t.Error("unknown variable accepted")
+	}
+
+	if err := ValidateSnapshotNameTemplate("snap-%{counter}-%F"); err != nil {
+		t.Errorf("valid template rejected - %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "counters.json")); err != nil {
+		t.Errorf("counter not persisted - %v", err)
+	}
+}
@@ -0,0 +1,333 @@
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SnapshotQuery filters snapshots.
+//
+// A query consists of white space separated terms - all terms must match:
+//
+//	name=daily-*          glob pattern on the snapshot name
+//	name~^daily-\d+$      regular expression on the snapshot name ('~', '!~')
+//	created>=2020-05-01   created at or after the date ('<', '<=', '>', '>=')
+//	created>30d           created in the last 30 days (units: s, m, h, d, w)
+//	used>=1M              minimum used size (units: K, M, G, T)
+//	holds>0               number of holds ('=', '!=', '<', '<=', '>', '>=')
+//	com.sun:auto=true     user property equals ('=', '!=')
+//
+// Glob patterns and user properties also accept '!=' for a negated match.
+// A user property which is not set on the snapshot has an empty value.
+// Terms with white space must be double quoted: "com.example:note=two words".
+type SnapshotQuery struct {
+	terms []queryTerm
+}
+
+type queryTerm struct {
+	raw   string
+	key   string
+	op    string
+	match func(Snapshot, map[string]string) bool
+}
+
+// the operators - longest first
+var queryOperators = []string{"<=", ">=", "!=", "!~", "=", "~", "<", ">"}
+
+var (
+	relativeTimeRE = regexp.MustCompile(`^(\d+)([smhdw])$`)
+	sizeRE         = regexp.MustCompile(`(?i)^(\d+(?:\.\d+)?)([KMGTP]?)(?:i?B)?$`)
+)
+
+// ParseSnapshotQuery parses the given query - an empty query matches all snapshots
+func ParseSnapshotQuery(query string) (SnapshotQuery, error) {
+	return parseSnapshotQuery(query, time.Now())
+}
+
+func parseSnapshotQuery(query string, now time.Time) (SnapshotQuery, error) {
+	var q SnapshotQuery
+	raws, err := splitQuery(query)
+	if err != nil {
+		return SnapshotQuery{}, err
+	}
+	for _, raw := range raws {
+		term, err := parseQueryTerm(raw, now)
+		if err != nil {
+			return SnapshotQuery{}, fmt.Errorf("invalid query term: '%s' - %v", raw, err)
+		}
+		q.terms = append(q.terms, term)
+	}
+	return q, nil
+}
+
+func parseQueryTerm(raw string, now time.Time) (queryTerm, error) {
+	term := queryTerm{raw: raw}
+	for _, op := range queryOperators {
+		if idx := strings.Index(raw, op); idx > 0 {
+			// the first operator in the term wins
+			if term.op == "" || idx < len(term.key) {
+				term.key, term.op = raw[:idx], op
+			}
+		}
+	}
+	if term.op == "" {
+		return term, fmt.Errorf("operator missing - expected one of: %s (double quote terms with white space)", strings.Join(queryOperators, " "))
+	}
+	value := raw[len(term.key)+len(term.op):]
+
+	switch {
+	case term.key == "name":
+		return term, term.nameMatcher(value)
+
+	case term.key == "created":
+		ts, err := parseQueryTime(value, now)
+		if err != nil {
+			return term, err
+		}
+		cmp, err := compareFunc(term.op)
+		if err != nil {
+			return term, err
+		}
+		term.match = func(s Snapshot, _ map[string]string) bool {
+			return cmp(s.Created.Unix(), ts.Unix())
+		}
+
+	case term.key == "used":
+		size, err := parseQuerySize(value)
+		if err != nil {
+			return term, err
+		}
+		cmp, err := compareFunc(term.op)
+		if err != nil {
+			return term, err
+		}
+		term.match = func(s Snapshot, _ map[string]string) bool {
+			return cmp(int64(s.Used), int64(size))
+		}
+
+	case term.key == "holds":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return term, fmt.Errorf("invalid number: '%s'", value)
+		}
+		cmp, err := compareFunc(term.op)
+		if err != nil {
+			return term, err
+		}
+		term.match = func(s Snapshot, _ map[string]string) bool {
+			return cmp(int64(s.Holds), n)
+		}
+
+	case isUserProperty(term.key):
+		if term.op != "=" && term.op != "!=" {
+			return term, fmt.Errorf("unsupported operator: '%s' for a user property", term.op)
+		}
+		key, negate := term.key, term.op == "!="
+		term.match = func(_ Snapshot, props map[string]string) bool {
+			return (props[key] == value) != negate
+		}
+
+	default:
+		return term, fmt.Errorf("unknown key: '%s'", term.key)
+	}
+	return term, nil
+}
+
+func (self *queryTerm) nameMatcher(value string) error {
+	switch self.op {
+	case "=", "!=":
+		if _, err := path.Match(value, ""); err != nil {
+			return fmt.Errorf("invalid pattern: '%s' - %v", value, err)
+		}
+		negate := self.op == "!="
+		self.match = func(s Snapshot, _ map[string]string) bool {
+			matched, _ := path.Match(value, s.Name)
+			return matched != negate
+		}
+	case "~", "!~":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return fmt.Errorf("invalid regular expression: '%s' - %v", value, err)
+		}
+		negate := self.op == "!~"
+		self.match = func(s Snapshot, _ map[string]string) bool {
+			return re.MatchString(s.Name) != negate
+		}
+	default:
+		return fmt.Errorf("unsupported operator: '%s' for the name", self.op)
+	}
+	return nil
+}
+
+// splitQuery splits the query at white space - double quotes group a term with white space
+func splitQuery(query string) ([]string, error) {
+	var terms []string
+	var term strings.Builder
+	inTerm, quoted := false, false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			inTerm, quoted = true, !quoted
+		case unicode.IsSpace(r) && !quoted:
+			if inTerm {
+				terms = append(terms, term.String())
+				term.Reset()
+			}
+			inTerm = false
+		default:
+			inTerm = true
+			term.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("invalid query: unterminated quote in: '%s'", query)
+	}
+	if inTerm {
+		terms = append(terms, term.String())
+	}
+	return terms, nil
+}
+
+func compareFunc(op string) (func(a, b int64) bool, error) {
+	switch op {
+	case "=":
+		return func(a, b int64) bool { return a == b }, nil
+	case "!=":
+		return func(a, b int64) bool { return a != b }, nil
+	case "<":
+		return func(a, b int64) bool { return a < b }, nil
+	case "<=":
+		return func(a, b int64) bool { return a <= b }, nil
+	case ">":
+		return func(a, b int64) bool { return a > b }, nil
+	case ">=":
+		return func(a, b int64) bool { return a >= b }, nil
+	}
+	return nil, fmt.Errorf("unsupported operator: '%s'", op)
+}
+
+// parseQueryTime parses a date (in the local timezone) or a relative time like '30d'
+func parseQueryTime(value string, now time.Time) (time.Time, error) {
+	if m := relativeTimeRE.FindStringSubmatch(value); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		unit := map[string]time.Duration{
+			"s": time.Second, "m": time.Minute, "h": time.Hour, "d": 24 * time.Hour, "w": 7 * 24 * time.Hour,
+		}[m[2]]
+		return now.Add(-time.Duration(n) * unit), nil
+	}
+
+	for _, layout := range []string{"2006-01-02", "2006-01-02T15:04", "2006-01-02T15:04:05", time.RFC3339} {
+		if ts, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return ts, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time: '%s' - expected a date like '2006-01-02' or an age like '30d'", value)
+}
+
+// parseQuerySize parses a size like '512', '10K' or '1.5G'
+func parseQuerySize(value string) (uint64, error) {
+	m := sizeRE.FindStringSubmatch(value)
+	if m == nil {
+		return 0, fmt.Errorf("invalid size: '%s'", value)
+	}
+
+	n, _ := strconv.ParseFloat(m[1], 64)
+	if m[2] != "" {
+		exp := strings.Index("KMGTP", strings.ToUpper(m[2])) + 1
+		n *= math.Pow(1024, float64(exp))
+	}
+	return uint64(n), nil
+}
+
+// IsEmpty checks if the query has no terms - it matches all snapshots
+func (self SnapshotQuery) IsEmpty() bool {
+	return len(self.terms) == 0
+}
+
+// UserProperties returns the user properties which are used in the query
+func (self SnapshotQuery) UserProperties() []string {
+	var props []string
+	seen := make(map[string]bool)
+	for _, t := range self.terms {
+		if isUserProperty(t.key) && !seen[t.key] {
+			seen[t.key] = true
+			props = append(props, t.key)
+		}
+	}
+	return props
+}
+
+// Match checks if the snapshot matches all terms.
+// 'props' are the values from the user properties of the snapshot.
+func (self SnapshotQuery) Match(snap Snapshot, props map[string]string) bool {
+	for _, t := range self.terms {
+		if !t.match(snap, props) {
+			return false
+		}
+	}
+	return true
+}
+
+func (self SnapshotQuery) String() string {
+	raw := make([]string, 0, len(self.terms))
+	for _, t := range self.terms {
+		raw = append(raw, t.raw)
+	}
+	return strings.Join(raw, " ")
+}
+
+// QuerySnapshots returns the snapshots which match the given query - newest first.
+//
+// The user properties from the query are fetched per additional 'zfs list' call.
+func (self *Dataset) QuerySnapshots(ctx context.Context, query SnapshotQuery) (Snapshots, error) {
+	snaps, err := self.ScanSnapshots(ctx)
+	if err != nil || query.IsEmpty() {
+		return snaps, err
+	}
+
+	props := make(map[string]map[string]string)
+	if userProps := query.UserProperties(); len(userProps) > 0 {
+		if props, err = self.snapshotUserProperties(ctx, userProps); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Debugf("query snapshots from dataset: %s - query: '%s'", self.Name, query)
+	return snaps.Filter(func(s Snapshot) bool {
+		return query.Match(s, props[s.FullName])
+	}), nil
+}
+
+// snapshotUserProperties returns the values per snapshot full name from the given user properties
+func (self *Dataset) snapshotUserProperties(ctx context.Context, names []string) (map[string]map[string]string, error) {
+	stdout, _, err := self.cmd.Exec(ctx,
+		"list -t snapshot -r -d 1 -Hp -o name,"+strings.Join(names, ","), self.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	props := make(map[string]map[string]string)
+	for _, line := range strings.Split(stdout, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != len(names)+1 {
+			if len(line) > 0 {
+				log.Tracef("ignore invalid formatted line: '%s'", line)
+			}
+			continue
+		}
+
+		values := make(map[string]string)
+		for i, name := range names {
+			values[name] = fields[i+1]
+		}
+		props[fields[0]] = values
+	}
+	return props, nil
+}
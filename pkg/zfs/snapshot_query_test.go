@@ -0,0 +1,50 @@
+package zfs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSnapshotQuery(t *testing.T) {
+	now := time.Date(2020, 6, 15, 12, 0, 0, 0, time.Local)
+	daily := Snapshot{Name: "daily-2020-05-10", Created: time.Date(2020, 5, 10, 0, 0, 0, 0, time.Local), Used: 2048}
+	hourly := Snapshot{Name: "hourly-2020-06-15", Created: now.Add(-time.Hour), Holds: 1}
+	props := map[string]string{"com.example:keep": "yes", "com.example:note": "two words"}
+
+	for query, expected := range map[string][2]bool{
+		"":                                       {true, true},
+		"name=daily-*":                           {true, false},
+		"name!=daily-*":                          {false, true},
+		`name~^hourly-\d+`:                       {false, true},
+		`name!~^hourly-\d+`:                      {true, false},
+		`"name=daily-2020-05-10 " name=daily-*`:  {false, false},
+		"created>=2020-05-01 created<2020-06-01": {true, false},
+		"created>2d":                             {false, true},
+		"used>=2K":                               {true, false},
+		"used<1.5k":                              {false, true},
+		"holds>0":                                {false, true},
+		"holds=0 name=daily-*":                   {true, false},
+		"com.example:keep=yes":                   {true, true},
+		"com.example:keep!=yes":                  {false, false},
+		`"com.example:note=two words"`:           {true, true},
+		`com.example:note="two words"`:           {true, true},
+		"com.example:missing=":                   {true, true},
+		"com.example:missing!=":                  {false, false},
+		"com.example:missing!=yes":               {true, true},
+	} {
+		q, err := parseSnapshotQuery(query, now)
+		if err != nil {
+			t.Errorf("query: '%s' - %v", query, err)
+			continue
+		}
+		if m := [2]bool{q.Match(daily, props), q.Match(hourly, props)}; m != expected {
+			t.Errorf("query: '%s' - unexpected matches: %v - expected: %v", query, m, expected)
+		}
+	}
+
+	for _, query := range []string{"name", "unknown=1", "name<daily", "name~(", "created>yesterday", "used>=1X", "holds>x", "a:b<1", "com.example:note=two words", `name="daily`} {
+		if _, err := ParseSnapshotQuery(query); err == nil {
+			t.Errorf("invalid query accepted: '%s'", query)
+		}
+	}
+}
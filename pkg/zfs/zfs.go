@@ -1,6 +1,7 @@
 package zfs
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/j-keck/plog"
@@ -10,33 +11,54 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 var log = plog.GlobalLogger()
 
-// ZFS represents a zfs filesystem
+// ZFS represents one or more zfs dataset trees
 type ZFS struct {
-	name     string
+	names []string
+	cmd   ZFSCmd
+	cache *SnapshotCache
+	// scan is shared between the copies from this handler
+	scan *datasetScan
+}
+
+// datasetScan are the datasets from the last scan.
+// the datasets are rescanned from concurrent requests - guarded per 'mutex'.
+type datasetScan struct {
+	mutex    sync.RWMutex
 	datasets Datasets
-	cmd      ZFSCmd
+	// otherMountNamespace is set if the mounts are from another mount namespace as the init process
+	otherMountNamespace bool
 }
 
-// NewZFS returns a handler for a zfs filesystem
-func NewZFS(name string) (ZFS, error) {
-	self := ZFS{}
-	self.name = name
-	self.cmd = NewZFSCmd(config.Get.ZFS.UseSudo)
-	ds, err := self.ScanDatasets()
-	if err != nil {
+// NewZFS returns a handler for the given zfs dataset trees
+func NewZFS(names ...string) (ZFS, error) {
+	return NewZFSWithCmd(NewZFSCmd(), names...)
+}
+
+// NewZFSWithCmd returns a handler for the given zfs dataset trees which uses
+// the given command to execute zfs commands (see 'zfstest.NewZFSCmdFake')
+func NewZFSWithCmd(cmd ZFSCmd, names ...string) (ZFS, error) {
+	self := ZFS{scan: &datasetScan{}}
+	if len(names) == 0 {
+		return self, errors.New("no dataset name given")
+	}
+
+	self.names = names
+	self.cmd = cmd
+	self.cache = NewSnapshotCache(config.Get.ZFS.SnapshotCacheTTL.Duration)
+	if _, err := self.ScanDatasets(context.Background()); err != nil {
 		return self, err
 	}
-	self.datasets = ds
 	return self, nil
 }
 
 func AvailableDatasetNames() ([]string, error) {
-	cmd := NewZFSCmd(config.Get.ZFS.UseSudo)
-	if stdout, _, err := cmd.Exec("list", "-H", "-t", "filesystem", "-o", "name"); err == nil {
+	cmd := NewZFSCmd()
+	if stdout, _, err := cmd.Exec(context.Background(), "list", "-H", "-t", "filesystem", "-o", "name"); err == nil {
 		datasetNames := strings.Split(stdout, "\n")
 		return datasetNames, nil
 	} else if _, ok := err.(ExecutableNotFound); ok {
@@ -46,9 +68,27 @@ func AvailableDatasetNames() ([]string, error) {
 	}
 }
 
+// AvailablePoolNames returns the names from all imported pools
+func AvailablePoolNames() ([]string, error) {
+	cmd := NewZFSCmd()
+	if stdout, _, err := cmd.Exec(context.Background(), "list", "-H", "-d", "0", "-t", "filesystem", "-o", "name"); err == nil {
+		var poolNames []string
+		for _, name := range strings.Split(stdout, "\n") {
+			if len(name) > 0 {
+				poolNames = append(poolNames, name)
+			}
+		}
+		return poolNames, nil
+	} else if _, ok := err.(ExecutableNotFound); ok {
+		return nil, errors.New("'zfs' executable not found. Try again with the '-use-sudo' flag")
+	} else {
+		return nil, err
+	}
+}
+
 func NewZFSForFilePath(path string) (ZFS, Dataset, error) {
-	cmd := NewZFSCmd(config.Get.ZFS.UseSudo)
-	stdout, _, err := cmd.Exec("list", "-Ho", "name")
+	cmd := NewZFSCmd()
+	stdout, _, err := cmd.Exec(context.Background(), "list", "-Ho", "name")
 	if err == nil {
 		for _, pool := range strings.Split(stdout, "\n") {
 			z, err := NewZFS(pool)
@@ -70,18 +110,33 @@ func NewZFSForFilePath(path string) (ZFS, Dataset, error) {
 	}
 }
 
-func (self *ZFS) Name() string {
-	return self.name
+// Names returns the names from the dataset trees of this handler
+func (self *ZFS) Names() []string {
+	return append([]string{}, self.names...)
+}
+
+// Contains checks if the given dataset name lives in one of the dataset trees
+func (self *ZFS) Contains(datasetName string) bool {
+	for _, name := range self.names {
+		if datasetName == name || strings.HasPrefix(datasetName, name+"/") {
+			return true
+		}
+	}
+	return false
 }
 
 func (self *ZFS) Datasets() Datasets {
-	datasets := make(Datasets, len(self.datasets))
-	copy(datasets, self.datasets)
+	self.scan.mutex.RLock()
+	defer self.scan.mutex.RUnlock()
+	datasets := make(Datasets, len(self.scan.datasets))
+	copy(datasets, self.scan.datasets)
 	return datasets
 }
 
-func (self *ZFS) ScanDatasets() (Datasets, error) {
-	datasets, ignored, err := self.scanDatasets(self.name)
+// ScanDatasets scans the datasets and replaces the datasets from this handler
+func (self *ZFS) ScanDatasets(ctx context.Context) (Datasets, error) {
+	mounts := newMountLookup()
+	datasets, ignored, err := self.scanAllDatasets(ctx, mounts)
 	if err != nil {
 
 		if _, ok := err.(ExecutableNotFound); ok {
@@ -92,7 +147,7 @@ func (self *ZFS) ScanDatasets() (Datasets, error) {
 			// lookup all dataset names and print them as a hint for the user
 			if datasetNames, e := AvailableDatasetNames(); e == nil {
 				names := strings.Join(datasetNames, ", ")
-				fmt.Errorf("%v\n\n  Possible dataset names: %s", err, names)
+				err = fmt.Errorf("%v\n\n  Possible dataset names: %s", err, names)
 			}
 		}
 		return nil, err
@@ -102,39 +157,105 @@ func (self *ZFS) ScanDatasets() (Datasets, error) {
 	log.Debugf("    %-40s %s", "Name", "Mountpoint")
 	for _, ds := range datasets {
 		log.Debugf("    %-40s %s", ds.Name, ds.MountPoint.Path)
+		for _, mp := range ds.BindMounts {
+			log.Debugf("    %-40s %s (bind mount)", "", mp)
+		}
 	}
 
 	log.Debugf("%d not mounted datasets ignored:", len(ignored))
 	for _, n := range ignored {
 		log.Debugf("    %s", n)
 	}
+
+	otherMountNamespace := mounts.InOtherNamespace()
+	if otherMountNamespace {
+		log.Warn("running in another mount namespace as the init process - " +
+			"datasets which are only mounted outside this namespace are reported as not mounted")
+	}
+
+	self.setDatasets(datasets, otherMountNamespace)
 	return datasets, nil
 }
 
-func (self *ZFS) RescanDatasets() error {
-	datasets, _, err := self.scanDatasets(self.name)
+// InOtherMountNamespace checks if the mounts from the last scan are from another
+// mount namespace as the init process (container, systemd 'ProtectSystem', ...).
+//
+// datasets which are only mounted outside this namespace are not found.
+func (self *ZFS) InOtherMountNamespace() bool {
+	self.scan.mutex.RLock()
+	defer self.scan.mutex.RUnlock()
+	return self.scan.otherMountNamespace
+}
+
+// SnapshotCache returns the snapshot inventory cache which is shared by all datasets
+func (self *ZFS) SnapshotCache() *SnapshotCache {
+	return self.cache
+}
+
+// RescanDatasets rescans the datasets and invalidates the snapshot cache
+func (self *ZFS) RescanDatasets(ctx context.Context) error {
+	self.cache.InvalidateAll()
+	mounts := newMountLookup()
+	datasets, _, err := self.scanAllDatasets(ctx, mounts)
 	if err != nil {
 		return err
 	}
 
-	self.datasets = datasets
+	self.setDatasets(datasets, mounts.InOtherNamespace())
 	return nil
 }
 
-// FindDatasetByName searches and returns the dataset with the given name
+func (self *ZFS) setDatasets(datasets Datasets, otherMountNamespace bool) {
+	self.scan.mutex.Lock()
+	defer self.scan.mutex.Unlock()
+	self.scan.datasets = datasets
+	self.scan.otherMountNamespace = otherMountNamespace
+}
+
+// scanAllDatasets returns the union from the datasets under all names.
+// overlapping dataset trees (like 'tank' and 'tank/home') are merged.
+func (self *ZFS) scanAllDatasets(ctx context.Context, mounts mountLookup) (Datasets, []string, error) {
+	var datasets Datasets
+	var ignored []string
+	seen := make(map[string]bool)
+	for _, name := range self.names {
+		ds, ign, err := self.scanDatasets(ctx, name, mounts)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, d := range ds {
+			if !seen[d.Name] {
+				seen[d.Name] = true
+				datasets = append(datasets, d)
+			}
+		}
+		ignored = append(ignored, ign...)
+	}
+	return datasets, ignored, nil
+}
+
+// FindDatasetByName searches and returns the dataset with the given name.
+// the error is classified as 'ErrorNotFound' if no dataset was found.
 func (self *ZFS) FindDatasetByName(name string) (Dataset, error) {
-	for _, dataset := range self.datasets {
+	for _, dataset := range self.Datasets() {
 		if dataset.Name == name {
 			return dataset, nil
 		}
 	}
-	return Dataset{}, fmt.Errorf("No dataset with name: '%s' found\n", name)
+	err := fmt.Errorf("No dataset with name: '%s' found", name)
+	return Dataset{}, ExecZFSError{err, ErrorNotFound}
 }
 
 func (self *ZFS) FindDatasetForPath(path string) (Dataset, error) {
 	datasets := self.Datasets()
 	sort.Sort(SortByPathDesc(datasets))
 	for _, ds := range datasets {
+		// the mountpoint from a locked dataset is not accessible
+		if ds.Locked || ds.MountPoint.Path == "" {
+			continue
+		}
+
 		// TODO: filepath.HasPrefix is buggy
 		//  see: https://github.com/golang/go/issues/18358
 		if filepath.HasPrefix(path, ds.MountPoint.Path) {
@@ -147,41 +268,82 @@ func (self *ZFS) FindDatasetForPath(path string) (Dataset, error) {
 	return Dataset{}, fmt.Errorf("No dataset for path: '%s' found\n", path)
 }
 
-// scanDatasets returns all datasets under a given pool name
-func (self *ZFS) scanDatasets(name string) (Datasets, []string, error) {
+// datasetColumns are the properties from the 'zfs list' call in 'scanDatasets'.
+// the mountpoint must be the last column.
+var datasetColumns = []string{
+	"name", "used", "avail", "refer", "origin", "encryption", "keystatus", "keylocation", "mountpoint",
+}
+
+// datasetColumnsWithoutEncryption are for zfs versions without native encryption
+var datasetColumnsWithoutEncryption = []string{"name", "used", "avail", "refer", "origin", "mountpoint"}
+
+// scanDatasets returns all datasets under a given pool name.
+// locked encrypted datasets are reported with 'Locked' set.
+// the legacy mountpoints and bind mounts are resolved per 'mounts'.
+func (self *ZFS) scanDatasets(ctx context.Context, name string, mounts mountLookup) (Datasets, []string, error) {
 	log.Debugf("search datasets under zfs: %s", name)
 
-	stdout, stderr, err := self.cmd.Exec("list -Hp -o name,used,avail,refer,mountpoint -r -t filesystem", name)
+	columns := datasetColumns
+	stdout, stderr, err := self.cmd.Exec(ctx, "list -Hp -o "+strings.Join(columns, ",")+" -r -t filesystem", name)
+	if err != nil && strings.Contains(stderr, "invalid property") {
+		// zfs versions without native encryption
+		log.Debugf("encryption properties not supported - %s", stderr)
+		columns = datasetColumnsWithoutEncryption
+		stdout, stderr, err = self.cmd.Exec(ctx, "list -Hp -o "+strings.Join(columns, ",")+" -r -t filesystem", name)
+	}
 	if err != nil {
 		log.Debugf("unable to search datasets: %s - %v", stderr, err)
 		return nil, nil, err
 	}
 
-	// parse a line from the zfs output
-	parse := func(s string) (string, uint64, uint64, uint64, string, bool) {
-		const n = 5
-		fields := strings.SplitN(s, "\t", n)
-		if len(fields) == n {
-			n1, err := strconv.ParseUint(fields[1], 10, 64)
-			if err != nil {
-				log.Warnf("invalid number in 'used': %v", err)
-				return "", 0, 0, 0, "", false
-			}
-			n2, err := strconv.ParseUint(fields[2], 10, 64)
-			if err != nil {
-				log.Warnf("invalid number in 'avail': %v", err)
-				return "", 0, 0, 0, "", false
-			}
-			n3, err := strconv.ParseUint(fields[3], 10, 64)
-			if err != nil {
-				log.Warnf("invalid number in 'refer': %v", err)
-				return "", 0, 0, 0, "", false
-			}
+	// parse a line from the zfs output - returns the dataset without the mountpoint
+	parse := func(s string) (Dataset, string, bool) {
+		// the mountpoint is the last column
+		fields := strings.SplitN(s, "\t", len(columns))
+		if len(fields) != len(columns) {
+			return Dataset{}, "", false
+		}
 
-			return fields[0], n1, n2, n3, fields[4], true
-		} else {
-			return "", 0, 0, 0, "", false
+		ds := Dataset{cmd: self.cmd, cache: self.cache}
+		for i, column := range columns {
+			value := fields[i]
+			switch column {
+			case "name":
+				ds.Name = value
+			case "used", "avail", "refer":
+				n, err := strconv.ParseUint(value, 10, 64)
+				if err != nil {
+					log.Warnf("invalid number in '%s': %v", column, err)
+					return Dataset{}, "", false
+				}
+				switch column {
+				case "used":
+					ds.Used = n
+				case "avail":
+					ds.Avail = n
+				case "refer":
+					ds.Refer = n
+				}
+			case "origin":
+				if value != "-" {
+					ds.Origin = value
+				}
+			case "encryption":
+				if value != "off" && value != "-" {
+					ds.Encryption = value
+				}
+			case "keystatus":
+				if value != "-" {
+					ds.KeyStatus = value
+				}
+			case "keylocation":
+				if value != "none" && value != "-" {
+					ds.KeyLocation = value
+				}
+			}
 		}
+		ds.Locked = ds.IsEncrypted() && ds.KeyStatus == "unavailable"
+		return ds, fields[len(fields)-1], true
 	}
 
 	// iterate over every line from the 'zfs list ...' output.
@@ -189,13 +351,31 @@ func (self *ZFS) scanDatasets(name string) (Datasets, []string, error) {
 	var datasets Datasets
 	var ignored []string
 	for _, line := range strings.Split(stdout, "\n") {
-		if name, used, avail, refer, mountPoint, ok := parse(line); ok {
-			switch mountPoint {
-			case "legacy":
+		if ds, mountPoint, ok := parse(line); ok {
+			name := ds.Name
+			switch {
+			case mountPoint == "none":
+				log.Tracef("ignore not mounted dataset: '%s'", name)
+				ignored = append(ignored, name)
+				continue
+
+			case ds.Locked:
+				// the mountpoint is not accessible - report the dataset as locked
+				log.Debugf("encrypted dataset: '%s' is locked - the key is not loaded", name)
+				if mountPoint != "legacy" {
+					ds.MountPoint = fs.DirHandle{FSHandle: fs.FSHandle{
+						Name: filepath.Base(mountPoint),
+						Path: mountPoint,
+						Kind: fs.DIR,
+					}}
+				}
+				datasets = append(datasets, ds)
+
+			case mountPoint == "legacy":
 				// lookup real mount point
 				log.Tracef("dataset: '%s' has legacy mountpoint - try to find the mountpoint", name)
 
-				legacyMountPoint, err := findmnt(name)
+				legacyMountPoint, err := mounts.MountPoint(name)
 				if err != nil {
 					log.Tracef("%s ist not mounted - ignore", name)
 					ignored = append(ignored, name)
@@ -204,21 +384,20 @@ func (self *ZFS) scanDatasets(name string) (Datasets, []string, error) {
 					if dirHandle, err := fs.GetDirHandle(legacyMountPoint); err != nil {
 						return nil, nil, err
 					} else {
-						datasets = append(datasets, Dataset{name, used, avail, refer, dirHandle, self.cmd})
+						ds.MountPoint = dirHandle
+						ds.BindMounts = mounts.BindMounts(name)
+						datasets = append(datasets, ds)
 					}
 				}
 
-			case "none":
-				log.Tracef("ignore not mounted dataset: '%s'", name)
-				ignored = append(ignored, name)
-				continue
-
 			default:
 				log.Tracef("dataset found - name: '%s', mountpoint: '%s'", name, mountPoint)
 				if dirHandle, err := fs.GetDirHandle(mountPoint); err != nil {
 					log.Warnf("unable to stat directory for dataset: %s - err: %s", name, err)
 				} else {
-					datasets = append(datasets, Dataset{name, used, avail, refer, dirHandle, self.cmd})
+					ds.MountPoint = dirHandle
+					ds.BindMounts = mounts.BindMounts(name)
+					datasets = append(datasets, ds)
 				}
 			}
 		} else {
@@ -228,9 +407,9 @@ func (self *ZFS) scanDatasets(name string) (Datasets, []string, error) {
 	return datasets, ignored, nil
 }
 
-func (self *ZFS) MountSnapshot(snap Snapshot) error {
+func (self *ZFS) MountSnapshot(ctx context.Context, snap Snapshot) error {
 	log.Debugf("mount snapshot: %s", snap.Name)
-	stdout, stderr, err := self.cmd.Exec("mount", snap.FullName)
+	stdout, stderr, err := self.cmd.Exec(ctx, "mount", snap.FullName)
 	log.Tracef("mount snapshot stdout: %s", stdout)
 	log.Tracef("mount snapshot stderr: %s", stderr)
 	return err
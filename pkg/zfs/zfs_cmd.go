@@ -2,62 +2,128 @@ package zfs
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"github.com/j-keck/zfs-snap-diff/pkg/config"
+	"github.com/j-keck/zfs-snap-diff/pkg/transport"
+	"io"
+	"io/ioutil"
 	"os/exec"
 	"strings"
+	"syscall"
 )
 
 type Stdout = string
 type Stderr = string
 
+// ZFSCmd executes zfs commands.
+//
+// The command gets killed when the context is done - or when the
+// configured timeout for the zfs sub-command has elapsed.
 type ZFSCmd interface {
-	Exec(string, ...string) (Stdout, Stderr, error)
+	Exec(context.Context, string, ...string) (Stdout, Stderr, error)
+	// ExecPipe connects the given reader / writer with stdin / stdout from the zfs command.
+	// The reader can be nil if the command does not read from stdin.
+	ExecPipe(context.Context, io.Reader, io.Writer, string, ...string) (Stderr, error)
 }
 
-func NewZFSCmd(useSudo bool) ZFSCmd {
-	return &zfsCmdImpl{useSudo}
+// NewZFSCmd returns the zfs command executor.
+// commands are elevated per the configured escalation policy - see 'ZFSConfig.EscalationFor',
+// and executed per the configured transport - see 'transport.Get'.
+func NewZFSCmd() ZFSCmd {
+	return &zfsCmdImpl{}
 }
 
 func NewZFSCmdMock(stdout Stdout, stderr Stderr, err error) ZFSCmd {
 	return &zfsCmdMock{stdout, stderr, err}
 }
 
-type zfsCmdImpl struct {
-	useSudo bool
+type zfsCmdImpl struct{}
+
+func (self *zfsCmdImpl) Exec(ctx context.Context, first string, rest ...string) (Stdout, Stderr, error) {
+	var stdoutBuf bytes.Buffer
+	if stderr, err := self.ExecPipe(ctx, nil, &stdoutBuf, first, rest...); err != nil {
+		return "", stderr, err
+	}
+
+	stdout := strings.TrimRight(stdoutBuf.String(), "\n")
+	return stdout, "", nil
 }
 
-func (self *zfsCmdImpl) Exec(first string, rest ...string) (Stdout, Stderr, error) {
+func (self *zfsCmdImpl) ExecPipe(ctx context.Context, stdin io.Reader, stdout io.Writer, first string, rest ...string) (Stderr, error) {
 	// build args
 	args := []string{"zfs"}
 	args = append(args, strings.Split(first, " ")...)
 	args = append(args, rest...)
-	if self.useSudo {
-		// prepend 'sudo'
-		args = append([]string{"sudo"}, args...)
+	subCmd := args[1]
+	if prefix := config.Get.ZFS.EscalationFor(subCmd); len(prefix) > 0 {
+		// prepend the escalation command - like 'sudo'
+		args = append(append([]string{}, prefix...), args...)
 	}
 
-	log.Debugf("exceute: %s", strings.Join(args, " "))
-	cmd := exec.Command(args[0], args[1:]...)
+	ctx, cancel := withTimeout(ctx, subCmd)
+	defer cancel()
 
-	var stdoutBuf bytes.Buffer
-	cmd.Stdout = &stdoutBuf
+	tp := transport.Get()
+	log.Debugf("exceute: %s (transport: %s)", strings.Join(args, " "), tp)
+	cmd := tp.Command(ctx, args[0], args[1:]...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
 
 	var stderrBuf bytes.Buffer
 	cmd.Stderr = &stderrBuf
 
-	if err := cmd.Run(); err != nil {
+	if err := runProcessGroup(ctx, cmd); err != nil {
 		stderr := strings.TrimRight(stderrBuf.String(), "\n")
 		log.Debugf("zfs cmd failed - err: '%v', stderr: '%s'", err, stderr)
 
+		// the process was killed
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return stderr, newAbortedZFSError(fmt.Errorf("zfs %s aborted - %v", subCmd, ctxErr), ctxErr)
+		}
+
 		if _, ok := err.(*exec.ExitError); ok {
-			return "", stderr, ExecZFSError{errors.New(stderr)}
+			return stderr, newExecZFSError(errors.New(stderr))
 		}
 
-		return "", stderr, ExecutableNotFound{err}
+		return stderr, ExecutableNotFound{err}
 	}
 
-	stdout := strings.TrimRight(stdoutBuf.String(), "\n")
-	return stdout, "", nil
+	return "", nil
+}
+
+// runProcessGroup runs the command in its own process group.
+//
+// When the context is done, the whole process group gets killed - not only the
+// first process, which is the escalation command or the transport wrapper
+// (like 'sudo' or 'ssh') if configured.
+func runProcessGroup(ctx context.Context, cmd *exec.Cmd) error {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			log.Debugf("kill process group: %d - %v", cmd.Process.Pid, ctx.Err())
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		case <-done:
+		}
+	}()
+	return cmd.Wait()
+}
+
+// withTimeout returns a context which expires after the
+// configured timeout for the given zfs sub-command
+func withTimeout(ctx context.Context, subCmd string) (context.Context, context.CancelFunc) {
+	if timeout := config.Get.ZFS.TimeoutFor(subCmd); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
 }
 
 type zfsCmdMock struct {
@@ -66,7 +132,7 @@ type zfsCmdMock struct {
 	err    error
 }
 
-func (self *zfsCmdMock) Exec(first string, rest ...string) (Stdout, Stderr, error) {
+func (self *zfsCmdMock) Exec(ctx context.Context, first string, rest ...string) (Stdout, Stderr, error) {
 	log.Tracef("would execute: %s %s", first, strings.Join(rest, " "))
 	log.Tracef("  return - stdout: '%s', stderr: '%s', err: '%v'", self.stdout, self.stderr, self.err)
 	if self.err != nil {
@@ -74,3 +140,15 @@ func (self *zfsCmdMock) Exec(first string, rest ...string) (Stdout, Stderr, erro
 	}
 	return self.stdout, self.stderr, self.err
 }
+
+func (self *zfsCmdMock) ExecPipe(ctx context.Context, stdin io.Reader, stdout io.Writer, first string, rest ...string) (Stderr, error) {
+	log.Tracef("would execute: %s %s", first, strings.Join(rest, " "))
+	log.Tracef("  return - stdout: '%s', stderr: '%s', err: '%v'", self.stdout, self.stderr, self.err)
+	if stdin != nil {
+		io.Copy(ioutil.Discard, stdin)
+	}
+	if stdout != nil {
+		io.WriteString(stdout, self.stdout)
+	}
+	return self.stderr, self.err
+}
@@ -1,22 +1,196 @@
 package zfs
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/j-keck/zfs-snap-diff/pkg/config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestScanDatasets(t *testing.T) {
-	out := `tank	1	2	3	testdata/tank
-tank/sub	1	2	3	testdata/tank/subpool
+	out := `tank	1	2	3	-	off	-	none	testdata/tank
+tank/sub	1	2	3	tank@snap	off	-	none	testdata/tank/subpool
+tank/home	1	2	3	-	aes-256-gcm	unavailable	prompt	/home
 `
 	zfs := new(ZFS)
 	zfs.cmd = NewZFSCmdMock(out, "", nil)
-	ds, _, err := zfs.scanDatasets("tank")
+	mounts := &mountTableLookup{load: func() (MountTable, error) {
+		return ParseMountInfo(strings.NewReader(
+			"40 22 0:40 / testdata/tank rw shared:1 - zfs tank rw\n" +
+				"41 22 0:40 /subpool /mnt/sub rw shared:1 - zfs tank rw\n"))
+	}}
+	ds, _, err := zfs.scanDatasets(context.Background(), "tank", mounts)
 	if err != nil {
 		t.Error(err)
 	}
 
-	expected := 2
+	expected := 3
 	if len(ds) != expected {
-		t.Errorf("%d datasets found - expected %d", len(ds), expected)
+		t.Fatalf("%d datasets found - expected %d", len(ds), expected)
+	}
+
+	if ds[0].Origin != "" || ds[1].Origin != "tank@snap" {
+		t.Errorf("unexpected origins: '%s', '%s'", ds[0].Origin, ds[1].Origin)
+	}
+
+	if len(ds[0].BindMounts) != 1 || ds[0].BindMounts[0] != "/mnt/sub" || len(ds[1].BindMounts) != 0 {
+		t.Errorf("unexpected bind mounts: %v, %v", ds[0].BindMounts, ds[1].BindMounts)
+	}
+
+	// locked datasets are reported - without accessing the mountpoint
+	if ds[0].Locked || !ds[2].Locked || ds[2].KeyLocation != "prompt" || ds[2].MountPoint.Path != "/home" {
+		t.Errorf("unexpected encrypted dataset: %+v", ds[2])
+	}
+}
+
+func TestSecret(t *testing.T) {
+	type Payload struct {
+		Passphrase Secret `json:"passphrase"`
+	}
+
+	var p Payload
+	if err := json.Unmarshal([]byte(`{"passphrase": "top secret"}`), &p); err != nil {
+		t.Fatal(err)
+	}
+	if string(p.Passphrase) != "top secret" {
+		t.Errorf("unexpected passphrase: '%s'", string(p.Passphrase))
+	}
+
+	js, _ := json.Marshal(p)
+	for _, s := range []string{fmt.Sprintf("%v %+v %#v %s %q", p, &p, p, p.Passphrase, p.Passphrase), string(js)} {
+		if strings.Contains(s, "top secret") {
+			t.Errorf("passphrase not masked: %s", s)
+		}
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	ctx, cancel := withTimeout(context.Background(), "list")
+	defer cancel()
+	if _, ok := ctx.Deadline(); !ok {
+		t.Error("no timeout for 'list'")
+	}
+
+	// streams are not limited per default
+	ctx, cancel = withTimeout(context.Background(), "send")
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("timeout for 'send'")
+	}
+}
+
+func TestErrorKind(t *testing.T) {
+	tests := map[string]ErrorKind{
+		"cannot open 'tank/fs2': dataset does not exist":                       ErrorNotFound,
+		"cannot destroy snapshots in tank/fs1@one: permission denied":          ErrorPermissionDenied,
+		"diff delegated permission is needed to execute the diff ioctl":        ErrorPermissionDenied,
+		"cannot destroy snapshot tank/fs1@one: dataset is busy":                ErrorBusy,
+		"cannot destroy 'tank/fs1@one': snapshot has dependent clones":         ErrorHasClones,
+		"cannot hold snapshot 'tank/fs1@one': tag already exists on this pool": ErrorUnknown,
+		"cannot open 'tank': pool I/O is currently suspended":                  ErrorPoolSuspended,
+		"cannot create snapshot 'tank/fs1@one': out of space":                  ErrorUnknown,
+	}
+
+	for msg, expected := range tests {
+		if kind := newExecZFSError(errors.New(msg)).Kind(); kind != expected {
+			t.Errorf("unexpected kind: '%s' for: '%s' - expected: '%s'", kind, msg, expected)
+		}
+	}
+
+	if kind := KindOf(errors.New("dataset is busy")); kind != ErrorUnknown {
+		t.Errorf("plain error classified as: '%s'", kind)
+	}
+}
+
+func TestEscalation(t *testing.T) {
+	cfg := config.ZFSConfig{EscalationCommand: "sudo -n -u zfsadmin", Escalate: []string{"mutating", "diff"}}
+
+	for subCmd, expected := range map[string]string{
+		"list":     "",
+		"get":      "",
+		"destroy":  "sudo -n -u zfsadmin",
+		"snapshot": "sudo -n -u zfsadmin",
+		"diff":     "sudo -n -u zfsadmin",
+	} {
+		if prefix := strings.Join(cfg.EscalationFor(subCmd), " "); prefix != expected {
+			t.Errorf("unexpected escalation for '%s': '%s' - expected: '%s'", subCmd, prefix, expected)
+		}
+	}
+
+	// '-use-sudo' elevates all commands
+	cfg = config.ZFSConfig{UseSudo: true}
+	if prefix := cfg.EscalationFor("list"); len(prefix) != 1 || prefix[0] != "sudo" {
+		t.Errorf("unexpected escalation with 'use-sudo': %v", prefix)
+	}
+}
+
+func TestTransportWrapper(t *testing.T) {
+	defer func(cfg config.Config) { config.Get = cfg }(config.Get)
+
+	// the wrapper prints the wrapped command instead of executing it
+	config.Get.Transport = config.TransportConfig{Wrapper: config.Argv{"sh", "-c", `echo "$@"`, "--"}}
+	config.Get.ZFS = config.ZFSConfig{EscalationCommand: "doas", Escalate: []string{"mutating"}}
+
+	cmd := NewZFSCmd()
+	for args, expected := range map[string]string{
+		"list -H":       "zfs list -H",
+		"destroy -n":    "doas zfs destroy -n",
+		"snapshot -r x": "doas zfs snapshot -r x",
+	} {
+		stdout, _, err := cmd.Exec(context.Background(), args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stdout != expected {
+			t.Errorf("unexpected command: '%s' - expected: '%s'", stdout, expected)
+		}
+	}
+}
+
+func TestTimeoutKillsProcessGroup(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("/proc not available")
+	}
+	defer func(cfg config.Config) { config.Get = cfg }(config.Get)
+
+	// the wrapper starts a child process which outlives the wrapper if only the wrapper gets killed
+	pidFile := filepath.Join(os.TempDir(), fmt.Sprintf("zsd-pgrp-%d", os.Getpid()))
+	defer os.Remove(pidFile)
+	config.Get.Transport = config.TransportConfig{Wrapper: config.Argv{"sh", "-c", `sleep 30 & echo $! > "$0"; wait`, pidFile}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, _, err := NewZFSCmd().Exec(ctx, "list"); KindOf(err) != ErrorTimeout {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("command not aborted - took: %s", d)
+	}
+
+	pid, err := ioutil.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the child is gone - or a zombie which is not reaped in a container
+	time.Sleep(100 * time.Millisecond)
+	if stat, err := ioutil.ReadFile("/proc/" + strings.TrimSpace(string(pid)) + "/stat"); err == nil && !strings.Contains(string(stat), ") Z") {
+		t.Errorf("child process still running: %s", stat)
+	}
+}
+
+func TestReceiveArgs(t *testing.T) {
+	args := strings.Join(receiveArgs([]string{"-s", "-u"}, "tank/restored"), " ")
+	if expected := "-u -x mountpoint -s tank/restored"; args != expected {
+		t.Errorf("unexpected receive args: '%s' - expected: '%s'", args, expected)
 	}
 }
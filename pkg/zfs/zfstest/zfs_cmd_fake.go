@@ -0,0 +1,1339 @@
+// Package zfstest provides a zfs fake for tests.
+package zfstest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/j-keck/plog"
+	"github.com/j-keck/zfs-snap-diff/pkg/zfs"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var log = plog.GlobalLogger()
+
+// ZFSCmdFake is a stateful, in-process zfs fake.
+//
+// Datasets are backed by plain directories under the given root directory,
+// snapshots are directory copies under '<MOUNTPOINT>/.zfs/snapshot/<NAME>'.
+//
+// It understands the commands: list, create, snapshot, destroy, rename,
+// clone, promote, rollback, mount, hold, release, holds, get, set, allow and load-key.
+// This is enough to test the scanner, the webapp handlers and zsd
+// end to end without a zfs pool.
+type ZFSCmdFake struct {
+	mutex    sync.Mutex
+	root     string
+	lastTs   time.Time
+	txg      uint64
+	datasets map[string]*fakeDataset
+}
+
+type fakeDataset struct {
+	name       string
+	mountPoint string
+	origin     string
+	created    time.Time
+	// local property values
+	props map[string]string
+	// snapshots - oldest first
+	snapshots []*fakeSnapshot
+	// delegated permissions
+	allows   []fakeAllow
+	permSets map[string]string
+	// encryption - empty if the dataset is not encrypted
+	encryption string
+	passphrase string
+	keyLoaded  bool
+}
+
+type fakeAllow struct {
+	// scope is one of: Local, Descendent, Local+Descendent
+	scope string
+	// principal is like 'user alice', 'group staff' or 'everyone'
+	principal string
+	perms     string
+}
+
+type fakeSnapshot struct {
+	name    string
+	created time.Time
+	txg     uint64
+	guid    uint64
+	props   map[string]string
+	holds   map[string]time.Time
+}
+
+// NewZFSCmdFake returns a zfs fake which creates the dataset directories under 'root'
+func NewZFSCmdFake(root string) *ZFSCmdFake {
+	return &ZFSCmdFake{root: root, datasets: make(map[string]*fakeDataset)}
+}
+
+// CreateEncryptedDataset creates a new encrypted dataset with 'keylocation=prompt'.
+// The key is not loaded - like after a reboot.
+func (self *ZFSCmdFake) CreateEncryptedDataset(name, passphrase string) (string, error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if err := self.create(name, true); err != nil {
+		return "", err
+	}
+	ds := self.datasets[name]
+	ds.encryption, ds.passphrase = "aes-256-gcm", passphrase
+	return ds.mountPoint, nil
+}
+
+// CreateDataset creates a new dataset (like 'zfs create -p') and returns the mount point
+func (self *ZFSCmdFake) CreateDataset(name string) (string, error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if err := self.create(name, true); err != nil {
+		return "", err
+	}
+	return self.datasets[name].mountPoint, nil
+}
+
+func (self *ZFSCmdFake) Exec(ctx context.Context, first string, rest ...string) (zfs.Stdout, zfs.Stderr, error) {
+	return self.exec(ctx, "", first, rest...)
+}
+
+func (self *ZFSCmdFake) exec(ctx context.Context, stdin string, first string, rest ...string) (zfs.Stdout, zfs.Stderr, error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return "", err.Error(), zfs.NewExecZFSError(err, err)
+	}
+
+	args := append(strings.Split(first, " "), rest...)
+	log.Tracef("fake execute: zfs %s", strings.Join(args, " "))
+
+	var stdout string
+	var err error
+	switch args[0] {
+	case "list":
+		stdout, err = self.list(args[1:])
+	case "create":
+		err = self.createCmd(args[1:])
+	case "snapshot":
+		err = self.snapshot(args[1:])
+	case "destroy":
+		stdout, err = self.destroy(args[1:])
+	case "rename":
+		err = self.rename(args[1:])
+	case "clone":
+		err = self.clone(args[1:])
+	case "promote":
+		err = self.promote(args[1:])
+	case "rollback":
+		err = self.rollback(args[1:])
+	case "mount":
+		// datasets and snapshots are always 'mounted'
+		err = self.mount(args[1:])
+	case "hold", "release":
+		err = self.holdOrRelease(args[0], args[1:])
+	case "holds":
+		stdout, err = self.holds(args[1:])
+	case "get":
+		stdout, err = self.get(args[1:])
+	case "set":
+		err = self.set(args[1:])
+	case "allow":
+		stdout, err = self.allow(args[1:])
+	case "load-key":
+		err = self.loadKey(args[1:], stdin)
+	default:
+		err = fmt.Errorf("unsupported command: '%s'", args[0])
+	}
+
+	if err != nil {
+		log.Tracef("fake execute failed: %v", err)
+		return "", err.Error(), zfs.NewExecZFSError(err, nil)
+	}
+	return strings.TrimRight(stdout, "\n"), "", nil
+}
+
+func (self *ZFSCmdFake) ExecPipe(ctx context.Context, stdin io.Reader, stdout io.Writer, first string, rest ...string) (zfs.Stderr, error) {
+	var in []byte
+	if stdin != nil {
+		in, _ = ioutil.ReadAll(stdin)
+	}
+
+	out, stderr, err := self.exec(ctx, string(in), first, rest...)
+	if err != nil {
+		return stderr, err
+	}
+	if stdout != nil {
+		io.WriteString(stdout, out)
+	}
+	return "", nil
+}
+
+// parseFlags splits the arguments in flags and positional arguments.
+// 'withValue' contains the flags which expect a value.
+func (self *ZFSCmdFake) parseFlags(args []string, withValue string) (map[string][]string, []string) {
+	flags := make(map[string][]string)
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || len(arg) < 2 {
+			positional = append(positional, arg)
+			continue
+		}
+
+		// combined flags like '-Hp' or '-Ho name'
+		for j := 1; j < len(arg); j++ {
+			f := string(arg[j])
+			if strings.Contains(withValue, f) {
+				var value string
+				if j+1 < len(arg) {
+					value = arg[j+1:]
+				} else if i+1 < len(args) {
+					i++
+					value = args[i]
+				}
+				flags[f] = append(flags[f], value)
+				break
+			}
+			flags[f] = append(flags[f], "")
+		}
+	}
+	return flags, positional
+}
+
+func (self *ZFSCmdFake) list(args []string) (string, error) {
+	flags, names := self.parseFlags(args, "odtsS")
+
+	props := []string{"name", "used", "avail", "refer", "mountpoint"}
+	if o, ok := flags["o"]; ok {
+		props = strings.Split(o[len(o)-1], ",")
+	}
+
+	types := map[string]bool{"filesystem": true}
+	if t, ok := flags["t"]; ok {
+		types = make(map[string]bool)
+		for _, typ := range strings.Split(t[len(t)-1], ",") {
+			if typ == "all" {
+				types["filesystem"], types["snapshot"] = true, true
+			}
+			types[typ] = true
+		}
+	}
+
+	_, recursive := flags["r"]
+	depth := -1
+	if d, ok := flags["d"]; ok {
+		recursive = true
+		depth, _ = strconv.Atoi(d[len(d)-1])
+	}
+
+	// without names, all datasets are listed
+	if len(names) == 0 {
+		for _, ds := range self.datasets {
+			if !strings.Contains(ds.name, "/") {
+				names = append(names, ds.name)
+			}
+		}
+		sort.Strings(names)
+		recursive = true
+	}
+
+	type row struct {
+		ds   *fakeDataset
+		snap *fakeSnapshot
+	}
+
+	var rows []row
+	for _, name := range names {
+		if strings.Contains(name, "@") {
+			ds, snap, err := self.lookupSnapshot(name)
+			if err != nil {
+				return "", err
+			}
+			rows = append(rows, row{ds, snap})
+			continue
+		}
+
+		root, ok := self.datasets[name]
+		if !ok {
+			return "", fmt.Errorf("cannot open '%s': dataset does not exist", name)
+		}
+
+		for _, ds := range self.sortedDatasets() {
+			level := -1
+			if ds.name == root.name {
+				level = 0
+			} else if recursive && strings.HasPrefix(ds.name, root.name+"/") {
+				level = strings.Count(strings.TrimPrefix(ds.name, root.name), "/")
+			}
+			if level < 0 || (depth >= 0 && level > depth) {
+				continue
+			}
+
+			if types["filesystem"] {
+				rows = append(rows, row{ds, nil})
+			}
+
+			// snapshots are one level below their dataset
+			if types["snapshot"] && (depth < 0 || level < depth) {
+				for _, snap := range ds.snapshots {
+					rows = append(rows, row{ds, snap})
+				}
+			}
+		}
+	}
+
+	value := func(r row, prop string) string {
+		if r.snap != nil {
+			return self.snapshotProp(r.ds, r.snap, prop)
+		}
+		return self.datasetProp(r.ds, prop)
+	}
+
+	sortBy := func(prop string, desc bool) {
+		sort.SliceStable(rows, func(i, j int) bool {
+			a, b := value(rows[i], prop), value(rows[j], prop)
+			na, errA := strconv.ParseInt(a, 10, 64)
+			nb, errB := strconv.ParseInt(b, 10, 64)
+			if errA == nil && errB == nil {
+				return na < nb != desc
+			}
+			return a < b != desc
+		})
+	}
+	if s, ok := flags["s"]; ok {
+		sortBy(s[len(s)-1], false)
+	}
+	if s, ok := flags["S"]; ok {
+		sortBy(s[len(s)-1], true)
+	}
+
+	var out strings.Builder
+	for _, r := range rows {
+		values := make([]string, len(props))
+		for i, prop := range props {
+			values[i] = value(r, prop)
+		}
+		out.WriteString(strings.Join(values, "\t") + "\n")
+	}
+	return out.String(), nil
+}
+
+func (self *ZFSCmdFake) datasetProp(ds *fakeDataset, prop string) string {
+	switch prop {
+	case "name":
+		return ds.name
+	case "type":
+		return "filesystem"
+	case "mountpoint":
+		return ds.mountPoint
+	case "creation":
+		return strconv.FormatInt(ds.created.Unix(), 10)
+	case "origin":
+		if ds.origin == "" {
+			return "-"
+		}
+		return ds.origin
+	case "encryption":
+		if ds.encryption == "" {
+			return "off"
+		}
+		return ds.encryption
+	case "keystatus":
+		switch {
+		case ds.encryption == "":
+			return "-"
+		case ds.keyLoaded:
+			return "available"
+		}
+		return "unavailable"
+	case "keylocation":
+		if ds.encryption == "" {
+			return "none"
+		}
+		return "prompt"
+	case "used", "avail", "available", "refer", "referenced":
+		return "0"
+	}
+
+	value, _ := self.propValue(ds, prop)
+	return value
+}
+
+// fakePropDefaults are the supported native properties with their default values
+var fakePropDefaults = []struct {
+	name, value string
+	inheritable bool
+}{
+	{"quota", "0", false},
+	{"reservation", "0", false},
+	{"refquota", "0", false},
+	{"compression", "off", true},
+	{"atime", "on", true},
+	{"snapdir", "hidden", true},
+}
+
+// propValue returns the value and the source ('zfs get' format) from the given property
+func (self *ZFSCmdFake) propValue(ds *fakeDataset, prop string) (string, string) {
+	if v, ok := ds.props[prop]; ok {
+		return v, "local"
+	}
+
+	inheritable := isUserProperty(prop)
+	defaultValue := "-"
+	for _, d := range fakePropDefaults {
+		if d.name == prop {
+			inheritable, defaultValue = d.inheritable, d.value
+		}
+	}
+
+	if inheritable {
+		for name := ds.name; strings.Contains(name, "/"); {
+			name = name[:strings.LastIndex(name, "/")]
+			if v, ok := self.datasets[name].props[prop]; ok {
+				return v, "inherited from " + name
+			}
+		}
+	}
+
+	if defaultValue == "-" {
+		return "-", "-"
+	}
+	return defaultValue, "default"
+}
+
+func (self *ZFSCmdFake) get(args []string) (string, error) {
+	flags, rest := self.parseFlags(args, "o")
+	if len(rest) != 2 {
+		return "", errors.New("missing property or dataset argument")
+	}
+
+	ds, ok := self.datasets[rest[1]]
+	if !ok {
+		return "", fmt.Errorf("cannot open '%s': dataset does not exist", rest[1])
+	}
+
+	fields := []string{"name", "property", "value", "source"}
+	if o, ok := flags["o"]; ok {
+		fields = strings.Split(o[len(o)-1], ",")
+	}
+
+	var props []string
+	if rest[0] == "all" {
+		props = []string{"type", "creation", "used", "available", "referenced", "mountpoint", "origin"}
+		for _, d := range fakePropDefaults {
+			props = append(props, d.name)
+		}
+
+		// user properties from the dataset and the parents
+		seen := make(map[string]bool)
+		var userProps []string
+		for name := range self.datasets {
+			if name == ds.name || strings.HasPrefix(ds.name, name+"/") {
+				for prop := range self.datasets[name].props {
+					if isUserProperty(prop) && !seen[prop] {
+						seen[prop] = true
+						userProps = append(userProps, prop)
+					}
+				}
+			}
+		}
+		sort.Strings(userProps)
+		props = append(props, userProps...)
+	} else {
+		props = strings.Split(rest[0], ",")
+	}
+
+	var out strings.Builder
+	for _, prop := range props {
+		value, source := self.datasetProp(ds, prop), "-"
+		if v, src := self.propValue(ds, prop); src != "-" {
+			value, source = v, src
+		}
+
+		values := make([]string, len(fields))
+		for i, field := range fields {
+			switch field {
+			case "name":
+				values[i] = ds.name
+			case "property":
+				values[i] = prop
+			case "value":
+				values[i] = value
+			case "source":
+				values[i] = source
+			}
+		}
+		out.WriteString(strings.Join(values, "\t") + "\n")
+	}
+	return out.String(), nil
+}
+
+func (self *ZFSCmdFake) set(args []string) error {
+	if len(args) != 2 {
+		return errors.New("missing property or dataset argument")
+	}
+
+	kv := strings.SplitN(args[0], "=", 2)
+	if len(kv) != 2 {
+		return fmt.Errorf("missing value in property=value argument")
+	}
+
+	ds, ok := self.datasets[args[1]]
+	if !ok {
+		return fmt.Errorf("cannot open '%s': dataset does not exist", args[1])
+	}
+
+	valid := isUserProperty(kv[0])
+	for _, d := range fakePropDefaults {
+		valid = valid || d.name == kv[0]
+	}
+	if !valid {
+		return fmt.Errorf("cannot set property for '%s': invalid property '%s'", ds.name, kv[0])
+	}
+
+	if ds.props == nil {
+		ds.props = make(map[string]string)
+	}
+	ds.props[kv[0]] = kv[1]
+	return nil
+}
+
+// allow lists the delegated permissions if only a dataset is given,
+// or delegates the permissions:
+//
+//	allow [-ld] -u user|-g group|-e perm[,perm] dataset
+//	allow -s @setname perm[,perm] dataset
+func (self *ZFSCmdFake) allow(args []string) (string, error) {
+	flags, positional := self.parseFlags(args, "ugs")
+	if len(positional) == 0 {
+		return "", errors.New("missing dataset argument")
+	}
+
+	name := positional[len(positional)-1]
+	ds, ok := self.datasets[name]
+	if !ok {
+		return "", fmt.Errorf("cannot open '%s': dataset does not exist", name)
+	}
+
+	if len(positional) == 1 && len(flags) == 0 {
+		return self.listAllows(ds), nil
+	}
+
+	if len(positional) != 2 {
+		return "", errors.New("missing permissions argument")
+	}
+	perms := positional[0]
+
+	if set, ok := flags["s"]; ok {
+		if ds.permSets == nil {
+			ds.permSets = make(map[string]string)
+		}
+		ds.permSets[set[0]] = perms
+		return "", nil
+	}
+
+	_, local := flags["l"]
+	_, descendent := flags["d"]
+	scope := "Local+Descendent"
+	if local && !descendent {
+		scope = "Local"
+	} else if descendent && !local {
+		scope = "Descendent"
+	}
+
+	var principal string
+	if u, ok := flags["u"]; ok {
+		principal = "user " + u[0]
+	} else if g, ok := flags["g"]; ok {
+		principal = "group " + g[0]
+	} else if _, ok := flags["e"]; ok {
+		principal = "everyone"
+	} else {
+		return "", errors.New("missing user, group or everyone argument")
+	}
+
+	ds.allows = append(ds.allows, fakeAllow{scope, principal, perms})
+	return "", nil
+}
+
+// listAllows formats the delegated permissions from the dataset and
+// the parent datasets like 'zfs allow <dataset>'
+func (self *ZFSCmdFake) listAllows(ds *fakeDataset) string {
+	var out strings.Builder
+	for name := ds.name; ; {
+		if d, ok := self.datasets[name]; ok && (len(d.allows) > 0 || len(d.permSets) > 0) {
+			fmt.Fprintf(&out, "---- Permissions on %s --------------------------------\n", name)
+			if len(d.permSets) > 0 {
+				out.WriteString("Permission sets:\n")
+				var sets []string
+				for set := range d.permSets {
+					sets = append(sets, set)
+				}
+				sort.Strings(sets)
+				for _, set := range sets {
+					fmt.Fprintf(&out, "\t%s %s\n", set, d.permSets[set])
+				}
+			}
+			for _, scope := range []string{"Local", "Descendent", "Local+Descendent"} {
+				header := false
+				for _, a := range d.allows {
+					if a.scope != scope {
+						continue
+					}
+					if !header {
+						fmt.Fprintf(&out, "%s permissions:\n", scope)
+						header = true
+					}
+					fmt.Fprintf(&out, "\t%s %s\n", a.principal, a.perms)
+				}
+			}
+		}
+
+		idx := strings.LastIndex(name, "/")
+		if idx == -1 {
+			break
+		}
+		name = name[:idx]
+	}
+	return out.String()
+}
+
+func (self *ZFSCmdFake) snapshotProp(ds *fakeDataset, snap *fakeSnapshot, prop string) string {
+	fullName := ds.name + "@" + snap.name
+	switch prop {
+	case "name":
+		return fullName
+	case "type":
+		return "snapshot"
+	case "creation":
+		return strconv.FormatInt(snap.created.Unix(), 10)
+	case "userrefs":
+		return strconv.Itoa(len(snap.holds))
+	case "clones":
+		return strings.Join(self.clonesOf(fullName), ",")
+	case "used", "refer", "referenced", "written":
+		return "0"
+	case "createtxg":
+		return strconv.FormatUint(snap.txg, 10)
+	case "guid":
+		return strconv.FormatUint(snap.guid, 10)
+	}
+
+	if v, ok := snap.props[prop]; ok {
+		return v
+	}
+	return "-"
+}
+
+func (self *ZFSCmdFake) createCmd(args []string) error {
+	flags, names := self.parseFlags(args, "o")
+	if len(names) != 1 {
+		return errors.New("missing filesystem argument")
+	}
+	_, parents := flags["p"]
+	return self.create(names[0], parents)
+}
+
+func (self *ZFSCmdFake) create(name string, parents bool) error {
+	if _, ok := self.datasets[name]; ok {
+		return fmt.Errorf("cannot create '%s': dataset already exists", name)
+	}
+
+	var mountPoint string
+	if idx := strings.LastIndex(name, "/"); idx > 0 {
+		parentName := name[:idx]
+		if _, ok := self.datasets[parentName]; !ok {
+			if !parents {
+				return fmt.Errorf("cannot create '%s': parent does not exist", name)
+			}
+			if err := self.create(parentName, parents); err != nil {
+				return err
+			}
+		}
+		mountPoint = filepath.Join(self.datasets[parentName].mountPoint, name[idx+1:])
+	} else {
+		mountPoint = filepath.Join(self.root, name)
+	}
+
+	if err := os.MkdirAll(mountPoint, 0755); err != nil {
+		return err
+	}
+	self.datasets[name] = &fakeDataset{name: name, mountPoint: mountPoint, created: self.now()}
+	return nil
+}
+
+func (self *ZFSCmdFake) snapshot(args []string) error {
+	flags, names := self.parseFlags(args, "o")
+	if len(names) == 0 {
+		return errors.New("missing snapshot argument")
+	}
+
+	props := make(map[string]string)
+	for _, o := range flags["o"] {
+		kv := strings.SplitN(o, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid property '%s'", o)
+		}
+		props[kv[0]] = kv[1]
+	}
+
+	// collect all snapshots at first - all or nothing
+	type target struct {
+		ds   *fakeDataset
+		name string
+	}
+	var targets []target
+	for _, fullName := range names {
+		fields := strings.SplitN(fullName, "@", 2)
+		if len(fields) != 2 || fields[1] == "" {
+			return fmt.Errorf("cannot create snapshot '%s': invalid name", fullName)
+		}
+
+		ds, ok := self.datasets[fields[0]]
+		if !ok {
+			return fmt.Errorf("cannot open '%s': dataset does not exist", fields[0])
+		}
+
+		datasets := []*fakeDataset{ds}
+		if _, ok := flags["r"]; ok {
+			datasets = self.descendants(ds)
+		}
+
+		for _, ds := range datasets {
+			if _, err := ds.snapshot(fields[1]); err == nil {
+				return fmt.Errorf("cannot create snapshot '%s@%s': dataset already exists", ds.name, fields[1])
+			}
+			targets = append(targets, target{ds, fields[1]})
+		}
+	}
+
+	// all snapshots from one call are created in the same transaction group
+	created := self.now()
+	self.txg++
+	for _, t := range targets {
+		snapProps := make(map[string]string, len(props))
+		for k, v := range props {
+			snapProps[k] = v
+		}
+
+		guid := fnv.New64a()
+		io.WriteString(guid, fmt.Sprintf("%s@%s-%d", t.ds.name, t.name, self.txg))
+		snap := &fakeSnapshot{name: t.name, created: created, txg: self.txg, guid: guid.Sum64(),
+			props: snapProps, holds: make(map[string]time.Time)}
+		if err := self.copyDir(t.ds.mountPoint, t.ds.snapshotPath(t.name), true); err != nil {
+			return err
+		}
+		t.ds.snapshots = append(t.ds.snapshots, snap)
+	}
+	return nil
+}
+
+// destroy destroys the given dataset / snapshot.
+// with '-n' nothing is destroyed - '-v' lists what would be destroyed.
+func (self *ZFSCmdFake) destroy(args []string) (string, error) {
+	flags, names := self.parseFlags(args, "")
+	if len(names) != 1 {
+		return "", errors.New("missing dataset argument")
+	}
+	_, recursive := flags["r"]
+	_, recursiveClones := flags["R"]
+	_, dryRun := flags["n"]
+	_, verbose := flags["v"]
+	name := names[0]
+
+	if strings.Contains(name, "@") {
+		ds, snap, err := self.lookupSnapshot(name)
+		if err != nil {
+			return "", err
+		}
+
+		targets := [][2]string{{ds.name, snap.name}}
+		if recursive {
+			targets = nil
+			for _, child := range self.descendants(ds) {
+				if _, err := child.snapshot(snap.name); err == nil {
+					targets = append(targets, [2]string{child.name, snap.name})
+				}
+			}
+		}
+
+		// validate all targets at first
+		for _, t := range targets {
+			fullName := t[0] + "@" + t[1]
+			_, s, _ := self.lookupSnapshot(fullName)
+			if len(s.holds) > 0 {
+				return "", fmt.Errorf("cannot destroy snapshot %s: dataset is busy", fullName)
+			}
+			if clones := self.clonesOf(fullName); len(clones) > 0 && !recursiveClones {
+				return "", fmt.Errorf("cannot destroy '%s': snapshot has dependent clones\n"+
+					"use '-R' to destroy the following datasets:\n%s", fullName, strings.Join(clones, "\n"))
+			}
+		}
+
+		if dryRun {
+			var destroyed []string
+			for _, t := range targets {
+				fullName := t[0] + "@" + t[1]
+				for _, clone := range self.clonesOf(fullName) {
+					destroyed = append(destroyed, self.destroyedWith(self.datasets[clone])...)
+				}
+				destroyed = append(destroyed, fullName)
+			}
+			return self.dryRunOutput(destroyed, verbose), nil
+		}
+
+		for _, t := range targets {
+			fullName := t[0] + "@" + t[1]
+			for _, clone := range self.clonesOf(fullName) {
+				if err := self.destroyDataset(self.datasets[clone], true); err != nil {
+					return "", err
+				}
+			}
+			if err := self.datasets[t[0]].removeSnapshot(t[1]); err != nil {
+				return "", err
+			}
+		}
+		return "", nil
+	}
+
+	ds, ok := self.datasets[name]
+	if !ok {
+		return "", fmt.Errorf("cannot open '%s': dataset does not exist", name)
+	}
+
+	if !recursive && !recursiveClones {
+		if len(ds.snapshots) > 0 || len(self.descendants(ds)) > 1 {
+			return "", fmt.Errorf("cannot destroy '%s': filesystem has children\n"+
+				"use '-r' to destroy the following datasets:", name)
+		}
+	}
+
+	if dryRun {
+		return self.dryRunOutput(self.destroyedWith(ds), verbose), nil
+	}
+	return "", self.destroyDataset(ds, recursiveClones)
+}
+
+// destroyedWith lists the datasets and snapshots which are destroyed
+// together with the given dataset - children first
+func (self *ZFSCmdFake) destroyedWith(ds *fakeDataset) []string {
+	var names []string
+	descendants := self.descendants(ds)
+	for i := len(descendants) - 1; i >= 0; i-- {
+		child := descendants[i]
+		for _, snap := range child.snapshots {
+			names = append(names, child.name+"@"+snap.name)
+		}
+		names = append(names, child.name)
+	}
+	return names
+}
+
+// dryRunOutput formats the output like 'zfs destroy -nvp'.
+// the fake doesn't track the used space - so nothing is reclaimed.
+func (self *ZFSCmdFake) dryRunOutput(names []string, verbose bool) string {
+	if !verbose {
+		return ""
+	}
+
+	var out strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&out, "destroy\t%s\n", name)
+	}
+	out.WriteString("reclaim\t0\n")
+	return out.String()
+}
+
+func (self *ZFSCmdFake) destroyDataset(ds *fakeDataset, withClones bool) error {
+	descendants := self.descendants(ds)
+
+	// validate at first
+	var clones []string
+	for _, child := range descendants {
+		for _, snap := range child.snapshots {
+			for _, clone := range self.clonesOf(child.name + "@" + snap.name) {
+				// clones in the same tree are destroyed anyway
+				if clone != ds.name && !strings.HasPrefix(clone, ds.name+"/") {
+					clones = append(clones, clone)
+				}
+			}
+		}
+	}
+
+	if len(clones) > 0 && !withClones {
+		return fmt.Errorf("cannot destroy '%s': filesystem has dependent clones\n"+
+			"use '-R' to destroy the following datasets:\n%s", ds.name, strings.Join(clones, "\n"))
+	}
+
+	for _, clone := range clones {
+		if c, ok := self.datasets[clone]; ok {
+			if err := self.destroyDataset(c, withClones); err != nil {
+				return err
+			}
+		}
+	}
+
+	// children first
+	for i := len(descendants) - 1; i >= 0; i-- {
+		child := descendants[i]
+		if err := os.RemoveAll(child.mountPoint); err != nil {
+			return err
+		}
+		delete(self.datasets, child.name)
+	}
+	return nil
+}
+
+func (self *ZFSCmdFake) rename(args []string) error {
+	_, names := self.parseFlags(args, "")
+	if len(names) != 2 {
+		return errors.New("missing source or target argument")
+	}
+	oldName, newName := names[0], names[1]
+
+	if strings.Contains(oldName, "@") {
+		ds, snap, err := self.lookupSnapshot(oldName)
+		if err != nil {
+			return err
+		}
+
+		fields := strings.SplitN(newName, "@", 2)
+		if len(fields) != 2 || fields[0] != ds.name {
+			return errors.New("snapshots must be part of same dataset")
+		}
+
+		if _, err := ds.snapshot(fields[1]); err == nil {
+			return fmt.Errorf("cannot rename to '%s': dataset already exists", newName)
+		}
+
+		if err := os.Rename(ds.snapshotPath(snap.name), ds.snapshotPath(fields[1])); err != nil {
+			return err
+		}
+
+		// keep the clones
+		for _, clone := range self.clonesOf(oldName) {
+			self.datasets[clone].origin = newName
+		}
+		snap.name = fields[1]
+		return nil
+	}
+
+	ds, ok := self.datasets[oldName]
+	if !ok {
+		return fmt.Errorf("cannot open '%s': dataset does not exist", oldName)
+	}
+
+	if _, ok := self.datasets[newName]; ok {
+		return fmt.Errorf("cannot rename to '%s': dataset already exists", newName)
+	}
+
+	idx := strings.LastIndex(newName, "/")
+	if idx < 0 {
+		return fmt.Errorf("cannot rename to '%s': datasets must be within same pool", newName)
+	}
+
+	parent, ok := self.datasets[newName[:idx]]
+	if !ok {
+		return fmt.Errorf("cannot rename to '%s': parent does not exist", newName)
+	}
+
+	newMountPoint := filepath.Join(parent.mountPoint, newName[idx+1:])
+	if err := os.Rename(ds.mountPoint, newMountPoint); err != nil {
+		return err
+	}
+
+	for _, child := range self.descendants(ds) {
+		delete(self.datasets, child.name)
+		child.name = newName + strings.TrimPrefix(child.name, oldName)
+		child.mountPoint = newMountPoint + strings.TrimPrefix(child.mountPoint, ds.mountPoint)
+		self.datasets[child.name] = child
+	}
+
+	// keep the clones
+	for _, other := range self.datasets {
+		if strings.HasPrefix(other.origin, oldName+"@") || strings.HasPrefix(other.origin, oldName+"/") {
+			other.origin = newName + strings.TrimPrefix(other.origin, oldName)
+		}
+	}
+	return nil
+}
+
+func (self *ZFSCmdFake) clone(args []string) error {
+	flags, names := self.parseFlags(args, "o")
+	if len(names) != 2 {
+		return errors.New("missing source or target argument")
+	}
+
+	ds, snap, err := self.lookupSnapshot(names[0])
+	if err != nil {
+		return err
+	}
+
+	_, parents := flags["p"]
+	if err := self.create(names[1], parents); err != nil {
+		return err
+	}
+
+	clone := self.datasets[names[1]]
+	clone.origin = ds.name + "@" + snap.name
+	return self.copyDir(ds.snapshotPath(snap.name), clone.mountPoint, false)
+}
+
+// promote moves the snapshots up to the origin from the origin dataset to the clone.
+// the origin dataset becomes a clone from the promoted dataset.
+func (self *ZFSCmdFake) promote(args []string) error {
+	_, names := self.parseFlags(args, "")
+	if len(names) != 1 {
+		return errors.New("missing clone argument")
+	}
+
+	clone, ok := self.datasets[names[0]]
+	if !ok {
+		return fmt.Errorf("cannot open '%s': dataset does not exist", names[0])
+	}
+	if clone.origin == "" {
+		return fmt.Errorf("cannot promote '%s': not a cloned filesystem", clone.name)
+	}
+
+	ds, origin, err := self.lookupSnapshot(clone.origin)
+	if err != nil {
+		return err
+	}
+
+	// the snapshots up to the origin - oldest first
+	var moved []*fakeSnapshot
+	for _, snap := range ds.snapshots {
+		if snap.created.After(origin.created) {
+			break
+		}
+		if _, err := clone.snapshot(snap.name); err == nil {
+			return fmt.Errorf("cannot promote '%s': snapshot name conflict: %s", clone.name, snap.name)
+		}
+		moved = append(moved, snap)
+	}
+
+	os.MkdirAll(filepath.Join(clone.mountPoint, ".zfs", "snapshot"), 0755)
+	for _, snap := range moved {
+		if err := os.Rename(ds.snapshotPath(snap.name), clone.snapshotPath(snap.name)); err != nil {
+			return err
+		}
+
+		// the clones from the moved snapshots are now clones from the promoted dataset
+		for _, other := range self.clonesOf(ds.name + "@" + snap.name) {
+			self.datasets[other].origin = clone.name + "@" + snap.name
+		}
+	}
+	ds.snapshots = ds.snapshots[len(moved):]
+	clone.snapshots = append(moved, clone.snapshots...)
+
+	// swap the origin
+	clone.origin, ds.origin = ds.origin, clone.name+"@"+origin.name
+	return nil
+}
+
+func (self *ZFSCmdFake) rollback(args []string) error {
+	flags, names := self.parseFlags(args, "")
+	if len(names) != 1 {
+		return errors.New("missing dataset argument")
+	}
+	_, recursive := flags["r"]
+	_, recursiveClones := flags["R"]
+
+	ds, snap, err := self.lookupSnapshot(names[0])
+	if err != nil {
+		return err
+	}
+
+	var newer []*fakeSnapshot
+	for _, s := range ds.snapshots {
+		if s.created.After(snap.created) {
+			newer = append(newer, s)
+		}
+	}
+
+	if len(newer) > 0 {
+		var names []string
+		for _, s := range newer {
+			names = append(names, ds.name+"@"+s.name)
+		}
+
+		if !recursive && !recursiveClones {
+			return fmt.Errorf("cannot rollback to '%s': more recent snapshots or bookmarks exist\n"+
+				"use '-r' to force deletion of the following snapshots and bookmarks:\n%s",
+				names[0], strings.Join(names, "\n"))
+		}
+
+		for _, name := range names {
+			if clones := self.clonesOf(name); len(clones) > 0 && !recursiveClones {
+				return fmt.Errorf("cannot rollback to '%s': clones of previous snapshots exist\n"+
+					"use '-R' to force deletion of the following clones and dependents:\n%s",
+					names[0], strings.Join(clones, "\n"))
+			}
+		}
+
+		for _, name := range names {
+			for _, clone := range self.clonesOf(name) {
+				if err := self.destroyDataset(self.datasets[clone], true); err != nil {
+					return err
+				}
+			}
+			if err := ds.removeSnapshot(strings.SplitN(name, "@", 2)[1]); err != nil {
+				return err
+			}
+		}
+	}
+
+	// replace the content - keep the '.zfs' directory and the child datasets
+	entries, err := ioutil.ReadDir(ds.mountPoint)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		p := filepath.Join(ds.mountPoint, e.Name())
+		if e.Name() == ".zfs" || self.isMountPoint(p) {
+			continue
+		}
+		if err := os.RemoveAll(p); err != nil {
+			return err
+		}
+	}
+	return self.copyDir(ds.snapshotPath(snap.name), ds.mountPoint, false)
+}
+
+func (self *ZFSCmdFake) mount(args []string) error {
+	_, names := self.parseFlags(args, "o")
+	for _, name := range names {
+		if strings.Contains(name, "@") {
+			if _, _, err := self.lookupSnapshot(name); err != nil {
+				return err
+			}
+		} else if ds, ok := self.datasets[name]; !ok {
+			return fmt.Errorf("cannot open '%s': dataset does not exist", name)
+		} else if ds.encryption != "" && !ds.keyLoaded {
+			return fmt.Errorf("cannot mount '%s': encryption key not loaded", name)
+		}
+	}
+	return nil
+}
+
+// loadKey loads the key per passphrase from stdin ('keylocation=prompt')
+func (self *ZFSCmdFake) loadKey(args []string, stdin string) error {
+	_, names := self.parseFlags(args, "L")
+	if len(names) != 1 {
+		return errors.New("missing dataset argument")
+	}
+
+	ds, ok := self.datasets[names[0]]
+	if !ok {
+		return fmt.Errorf("cannot open '%s': dataset does not exist", names[0])
+	}
+
+	switch {
+	case ds.encryption == "":
+		return fmt.Errorf("Key load error: Keys are not loaded for '%s': encryption is not enabled.", ds.name)
+	case ds.keyLoaded:
+		return fmt.Errorf("Key load error: Key already loaded for '%s'.", ds.name)
+	case strings.TrimSuffix(stdin, "\n") != ds.passphrase:
+		return fmt.Errorf("Key load error: Incorrect key provided for '%s'.", ds.name)
+	}
+	ds.keyLoaded = true
+	return nil
+}
+
+func (self *ZFSCmdFake) holdOrRelease(action string, args []string) error {
+	_, names := self.parseFlags(args, "")
+	if len(names) < 2 {
+		return errors.New("missing tag or snapshot argument")
+	}
+
+	tag := names[0]
+	for _, name := range names[1:] {
+		_, snap, err := self.lookupSnapshot(name)
+		if err != nil {
+			return err
+		}
+
+		_, exists := snap.holds[tag]
+		switch {
+		case action == "hold" && exists:
+			return fmt.Errorf("cannot hold snapshot '%s': tag already exists on this dataset", name)
+		case action == "hold":
+			snap.holds[tag] = self.now()
+		case !exists:
+			return fmt.Errorf("cannot release hold from snapshot '%s': no such tag on this dataset", name)
+		default:
+			delete(snap.holds, tag)
+		}
+	}
+	return nil
+}
+
+func (self *ZFSCmdFake) holds(args []string) (string, error) {
+	_, names := self.parseFlags(args, "")
+	var out strings.Builder
+	for _, name := range names {
+		_, snap, err := self.lookupSnapshot(name)
+		if err != nil {
+			return "", err
+		}
+
+		var tags []string
+		for tag := range snap.holds {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+
+		for _, tag := range tags {
+			fmt.Fprintf(&out, "%s\t%s\t%s\n", name, tag, snap.holds[tag].Format("Mon Jan _2 15:04 2006"))
+		}
+	}
+	return out.String(), nil
+}
+
+// now returns the current time - but always one second after the last returned time,
+// so every snapshot has a unique creation timestamp (zfs has a resolution of one second)
+func (self *ZFSCmdFake) now() time.Time {
+	ts := time.Now().Truncate(time.Second)
+	if !ts.After(self.lastTs) {
+		ts = self.lastTs.Add(time.Second)
+	}
+	self.lastTs = ts
+	return ts
+}
+
+func (self *ZFSCmdFake) lookupSnapshot(fullName string) (*fakeDataset, *fakeSnapshot, error) {
+	fields := strings.SplitN(fullName, "@", 2)
+	if len(fields) != 2 {
+		return nil, nil, fmt.Errorf("cannot open '%s': not a snapshot", fullName)
+	}
+
+	ds, ok := self.datasets[fields[0]]
+	if !ok {
+		return nil, nil, fmt.Errorf("cannot open '%s': dataset does not exist", fullName)
+	}
+
+	snap, err := ds.snapshot(fields[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot open '%s': dataset does not exist", fullName)
+	}
+	return ds, snap, nil
+}
+
+func (self *ZFSCmdFake) sortedDatasets() []*fakeDataset {
+	datasets := make([]*fakeDataset, 0, len(self.datasets))
+	for _, ds := range self.datasets {
+		datasets = append(datasets, ds)
+	}
+	sort.Slice(datasets, func(i, j int) bool { return datasets[i].name < datasets[j].name })
+	return datasets
+}
+
+// descendants returns the given dataset and all children - sorted by name
+func (self *ZFSCmdFake) descendants(ds *fakeDataset) []*fakeDataset {
+	var datasets []*fakeDataset
+	for _, other := range self.sortedDatasets() {
+		if other.name == ds.name || strings.HasPrefix(other.name, ds.name+"/") {
+			datasets = append(datasets, other)
+		}
+	}
+	return datasets
+}
+
+func (self *ZFSCmdFake) clonesOf(snapFullName string) []string {
+	var clones []string
+	for _, ds := range self.sortedDatasets() {
+		if ds.origin == snapFullName {
+			clones = append(clones, ds.name)
+		}
+	}
+	return clones
+}
+
+func (self *ZFSCmdFake) isMountPoint(path string) bool {
+	for _, ds := range self.datasets {
+		if ds.mountPoint == path {
+			return true
+		}
+	}
+	return false
+}
+
+// copyDir copies the content from 'src' into 'dst'. The '.zfs' directory is never copied.
+// Mount points of other datasets are created as empty directories if 'skipMountPoints' is set.
+func (self *ZFSCmdFake) copyDir(src, dst string, skipMountPoints bool) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, _ := filepath.Rel(src, p)
+		if rel == ".zfs" {
+			return filepath.SkipDir
+		}
+
+		target := filepath.Join(dst, rel)
+		switch {
+		case info.IsDir():
+			if err := os.MkdirAll(target, info.Mode().Perm()); err != nil {
+				return err
+			}
+			if rel != "." && skipMountPoints && self.isMountPoint(p) {
+				return filepath.SkipDir
+			}
+			return nil
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			buf, err := ioutil.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(target, buf, info.Mode().Perm()); err != nil {
+				return err
+			}
+			return os.Chtimes(target, info.ModTime(), info.ModTime())
+		}
+	})
+}
+
+func (self *fakeDataset) snapshotPath(name string) string {
+	return filepath.Join(self.mountPoint, ".zfs", "snapshot", name)
+}
+
+func (self *fakeDataset) snapshot(name string) (*fakeSnapshot, error) {
+	for _, snap := range self.snapshots {
+		if snap.name == name {
+			return snap, nil
+		}
+	}
+	return nil, fmt.Errorf("snapshot: '%s' not found", name)
+}
+
+func (self *fakeDataset) removeSnapshot(name string) error {
+	for i, snap := range self.snapshots {
+		if snap.name == name {
+			self.snapshots = append(self.snapshots[:i], self.snapshots[i+1:]...)
+			return os.RemoveAll(self.snapshotPath(name))
+		}
+	}
+	return fmt.Errorf("snapshot: '%s' not found", name)
+}
+
+func isUserProperty(name string) bool {
+	return strings.Contains(name, ":")
+}
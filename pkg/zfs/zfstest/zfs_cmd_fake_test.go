@@ -0,0 +1,666 @@
+package zfstest
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/j-keck/zfs-snap-diff/pkg/zfs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newFakeZFS(t *testing.T) (zfs.ZFS, *ZFSCmdFake, func()) {
+	root, err := ioutil.TempDir("", "zsd-fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := NewZFSCmdFake(root)
+	if _, err := fake.CreateDataset("tank/fs1/sub"); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zfs.NewZFSWithCmd(fake, "tank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return z, fake, func() { os.RemoveAll(root) }
+}
+
+func TestFakeDatasetLifecycle(t *testing.T) {
+	ctx := context.Background()
+	z, _, cleanup := newFakeZFS(t)
+	defer cleanup()
+
+	if len(z.Datasets()) != 3 {
+		t.Fatalf("%d datasets found - expected 3", len(z.Datasets()))
+	}
+
+	ds, err := z.FindDatasetByName("tank/fs1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(ds.MountPoint.Path, "file")
+	write := func(content string) {
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func() string {
+		buf, _ := ioutil.ReadFile(file)
+		return string(buf)
+	}
+
+	// create
+	write("v1")
+	if _, err := ds.CreateSnapshot(ctx, "one", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	write("v2")
+	if _, err := ds.CreateSnapshot(ctx, "two", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	write("v3")
+
+	snaps, err := ds.ScanSnapshots(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 2 || snaps[0].Name != "two" {
+		t.Fatalf("unexpected snapshots: %v", snaps)
+	}
+
+	buf, err := ioutil.ReadFile(filepath.Join(snaps[1].MountPoint.Path, "file"))
+	if err != nil || string(buf) != "v1" {
+		t.Errorf("unexpected content in snapshot: '%s' - %v", buf, err)
+	}
+
+	// rename
+	if err := ds.RenameSnapshot(ctx, "two", "second"); err != nil {
+		t.Fatal(err)
+	}
+
+	// hold prevents destroy
+	if err := ds.HoldSnapshot(ctx, "second", "keep", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.DestroySnapshot(ctx, "second", nil); err == nil {
+		t.Error("destroy with a hold succeeded")
+	}
+	if err := ds.ReleaseSnapshot(ctx, "second", "keep", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// rollback needs '-r' if newer snapshots exists
+	if err := ds.RollbackSnapshot(ctx, "one", nil); err == nil {
+		t.Error("rollback without '-r' succeeded")
+	}
+	if err := ds.RollbackSnapshot(ctx, "one", []string{"-r"}); err != nil {
+		t.Fatal(err)
+	}
+	if content := read(); content != "v1" {
+		t.Errorf("unexpected content after rollback: '%s'", content)
+	}
+
+	// clone
+	if err := ds.CloneSnapshot(ctx, "one", "tank/clone", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.DestroySnapshot(ctx, "one", nil); err == nil {
+		t.Error("destroy with a dependent clone succeeded")
+	}
+	if err := ds.DestroySnapshot(ctx, "one", []string{"-R"}); err != nil {
+		t.Fatal(err)
+	}
+
+	snaps, err = ds.ScanSnapshots(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 0 {
+		t.Errorf("%d snapshots found - expected 0", len(snaps))
+	}
+
+	if err := z.RescanDatasets(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := z.FindDatasetByName("tank/clone"); err == nil {
+		t.Error("clone not destroyed")
+	}
+}
+
+func TestCreateSnapshots(t *testing.T) {
+	ctx := context.Background()
+	z, fake, cleanup := newFakeZFS(t)
+	defer cleanup()
+
+	// recursive
+	ds, _ := z.FindDatasetByName("tank/fs1")
+	if _, err := ds.CreateSnapshot(ctx, "recursive", []string{"-r"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// atomic across datasets - with a user property
+	props := map[string]string{"com.example:reason": "upgrade"}
+	names, err := z.CreateSnapshots(ctx, []string{"tank", "tank/fs1/sub"}, "atomic", nil, props)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 {
+		t.Errorf("%d snapshots created - expected 2", len(names))
+	}
+
+	expected := map[string]int{"tank": 1, "tank/fs1": 1, "tank/fs1/sub": 2}
+	for name, n := range expected {
+		ds, _ := z.FindDatasetByName(name)
+		snaps, err := ds.ScanSnapshots(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(snaps) != n {
+			t.Errorf("%d snapshots for %s found - expected %d", len(snaps), name, n)
+		}
+	}
+
+	stdout, _, err := fake.Exec(ctx, "list -H -o com.example:reason", "tank@atomic")
+	if err != nil || stdout != "upgrade" {
+		t.Errorf("unexpected user property: '%s' - %v", stdout, err)
+	}
+
+	// only user properties are supported
+	if _, err := ds.CreateSnapshot(ctx, "invalid", nil, map[string]string{"compression": "on"}); err == nil {
+		t.Error("snapshot with a native property created")
+	}
+}
+
+func TestMultipleDatasetTrees(t *testing.T) {
+	root, err := ioutil.TempDir("", "zsd-fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	fake := NewZFSCmdFake(root)
+	for _, name := range []string{"tank/fs1", "backup/fs2"} {
+		if _, err := fake.CreateDataset(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 'tank/fs1' overlaps with 'tank'
+	z, err := zfs.NewZFSWithCmd(fake, "tank", "backup", "tank/fs1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(z.Datasets()) != 4 {
+		t.Errorf("%d datasets found - expected 4", len(z.Datasets()))
+	}
+
+	ds, err := z.FindDatasetByName("backup/fs2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := z.FindDatasetForPath(ds.MountPoint.Path + "/file"); err != nil {
+		t.Errorf("path in the second tree not found - %v", err)
+	}
+
+	if !z.Contains("backup/fs2/child") || z.Contains("other") {
+		t.Error("unexpected 'Contains' result")
+	}
+}
+
+func TestSnapshotCache(t *testing.T) {
+	ctx := context.Background()
+	z, fake, cleanup := newFakeZFS(t)
+	defer cleanup()
+
+	ds, _ := z.FindDatasetByName("tank/fs1")
+	if snaps, _ := ds.ScanSnapshots(ctx); len(snaps) != 0 {
+		t.Fatalf("%d snapshots found - expected 0", len(snaps))
+	}
+
+	// external modifications are not visible until the cache expires
+	if _, _, err := fake.Exec(ctx, "snapshot", "tank/fs1@external"); err != nil {
+		t.Fatal(err)
+	}
+	if snaps, _ := ds.ScanSnapshots(ctx); len(snaps) != 0 {
+		t.Errorf("cached snapshot list not used")
+	}
+
+	// own modifications invalidates the cache
+	if _, err := ds.CreateSnapshot(ctx, "own", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if snaps, _ := ds.ScanSnapshots(ctx); len(snaps) != 2 {
+		t.Errorf("%d snapshots found - expected 2", len(snaps))
+	}
+
+	// the cache survives a json round trip
+	j, err := json.Marshal(z.SnapshotCache())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	z2, err := zfs.NewZFSWithCmd(fake, "tank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(j, z2.SnapshotCache()); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := fake.Exec(ctx, "snapshot", "tank/fs1@external2"); err != nil {
+		t.Fatal(err)
+	}
+	ds2, _ := z2.FindDatasetByName("tank/fs1")
+	if snaps, _ := ds2.ScanSnapshots(ctx); len(snaps) != 2 {
+		t.Errorf("persisted cache entry not used - %d snapshots found - expected 2", len(snaps))
+	}
+}
+
+func TestDatasetProperties(t *testing.T) {
+	ctx := context.Background()
+	z, _, cleanup := newFakeZFS(t)
+	defer cleanup()
+
+	fs1, _ := z.FindDatasetByName("tank/fs1")
+	sub, _ := z.FindDatasetByName("tank/fs1/sub")
+
+	if err := fs1.SetProperty(ctx, "compression", "lz4"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fs1.SetProperty(ctx, "com.example:owner", "ops"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fs1.SetProperty(ctx, "mountpoint", "/other"); err == nil {
+		t.Error("not editable property accepted")
+	}
+
+	props, err := sub.GetProperties(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, ok := props.Get("compression")
+	if !ok || p.Value != "lz4" || p.Source != "inherited" || p.InheritedFrom != "tank/fs1" || !p.Editable {
+		t.Errorf("unexpected property: %+v", p)
+	}
+
+	p, ok = props.Get("quota")
+	if !ok || p.Value != uint64(0) || p.Source != "default" {
+		t.Errorf("unexpected property: %+v", p)
+	}
+
+	p, ok = props.Get("type")
+	if !ok || p.Source != "none" || p.Editable {
+		t.Errorf("unexpected property: %+v", p)
+	}
+
+	if p, ok := props.Get("com.example:owner"); !ok || p.Value != "ops" {
+		t.Errorf("unexpected property: %+v", p)
+	}
+}
+
+func TestImpactPreview(t *testing.T) {
+	ctx := context.Background()
+	z, _, cleanup := newFakeZFS(t)
+	defer cleanup()
+
+	ds, _ := z.FindDatasetByName("tank/fs1")
+	for _, name := range []string{"one", "two"} {
+		if _, err := ds.CreateSnapshot(ctx, name, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ds.CloneSnapshot(ctx, "two", "tank/clone", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// destroy
+	if _, err := ds.PreviewDestroySnapshot(ctx, "two", nil); err == nil {
+		t.Error("destroy preview of a snapshot with clones without '-R' succeeded")
+	}
+
+	preview, err := ds.PreviewDestroySnapshot(ctx, "two", []string{"-R"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(preview.Clones) != 1 || preview.Clones[0] != "tank/clone" {
+		t.Errorf("unexpected clones: %v", preview.Clones)
+	}
+	if len(preview.Destroyed) != 2 || preview.Destroyed[1] != "tank/fs1@two" {
+		t.Errorf("unexpected destroyed: %v", preview.Destroyed)
+	}
+
+	// rollback
+	if _, err := ds.PreviewRollbackSnapshot(ctx, "one", nil); err == nil {
+		t.Error("rollback preview with newer snapshots without '-r' succeeded")
+	}
+	if _, err := ds.PreviewRollbackSnapshot(ctx, "one", []string{"-r"}); err == nil {
+		t.Error("rollback preview with clones without '-R' succeeded")
+	}
+
+	preview, err = ds.PreviewRollbackSnapshot(ctx, "one", []string{"-R"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(preview.Clones) != 1 || len(preview.Destroyed) != 2 || preview.Destroyed[1] != "tank/fs1@two" {
+		t.Errorf("unexpected preview: %+v", preview)
+	}
+
+	// nothing destroyed
+	if snaps, _ := ds.ScanSnapshots(ctx); len(snaps) != 2 {
+		t.Errorf("%d snapshots after the preview - expected 2", len(snaps))
+	}
+	if err := z.RescanDatasets(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := z.FindDatasetByName("tank/clone"); err != nil {
+		t.Errorf("clone destroyed by the preview: %v", err)
+	}
+}
+
+func TestCancelledCommand(t *testing.T) {
+	z, _, cleanup := newFakeZFS(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	ds, _ := z.FindDatasetByName("tank/fs1")
+	if _, err := ds.CreateSnapshot(ctx, "one", nil, nil); err == nil {
+		t.Error("command executed with a cancelled context")
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	ctx := context.Background()
+	z, fake, cleanup := newFakeZFS(t)
+	defer cleanup()
+
+	for _, args := range [][]string{
+		{"-s", "@snap", "snapshot,mount", "tank"},
+		{"-u", "alice", "@snap,destroy", "tank"},
+		{"-l", "-g", "staff", "hold", "tank/fs1"},
+		{"-e", "diff", "tank/fs1"},
+	} {
+		if _, _, err := fake.Exec(ctx, "allow", args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	alice := zfs.Principal{User: "alice", Groups: []string{"staff"}}
+	expected := map[string]map[string]bool{
+		"tank/fs1":     {"snapshot": true, "destroy": true, "hold": true, "diff": true, "rollback": false},
+		"tank/fs1/sub": {"snapshot": true, "destroy": true, "hold": false, "diff": true, "rollback": false},
+	}
+	for name, ops := range expected {
+		ds, _ := z.FindDatasetByName(name)
+		caps, err := ds.Capabilities(ctx, alice)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for op, permitted := range ops {
+			if caps[op].Permitted != permitted {
+				t.Errorf("unexpected capability for '%s' on %s: %+v", op, name, caps[op])
+			}
+		}
+	}
+
+	ds, _ := z.FindDatasetByName("tank/fs1")
+	caps, _ := ds.Capabilities(ctx, zfs.Principal{User: "bob"})
+	if err := caps.Check("snapshot", ds.Name); zfs.KindOf(err) != zfs.ErrorPermissionDenied {
+		t.Errorf("unexpected check result: %v", err)
+	}
+
+	if caps, _ := ds.Capabilities(ctx, zfs.Principal{Root: true}); caps.Check("rollback", ds.Name) != nil {
+		t.Error("operation refused for root")
+	}
+}
+
+func TestCloneLifecycle(t *testing.T) {
+	ctx := context.Background()
+	z, _, cleanup := newFakeZFS(t)
+	defer cleanup()
+
+	ds, _ := z.FindDatasetByName("tank/fs1")
+	for _, name := range []string{"one", "two", "three"} {
+		if _, err := ds.CreateSnapshot(ctx, name, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range [][2]string{{"two", "tank/restore"}, {"three", "tank/test"}} {
+		if err := ds.CloneSnapshot(ctx, c[0], c[1], nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clones, err := ds.ListClones(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clones) != 2 || clones[0].Name != "tank/restore" || clones[0].Origin != "tank/fs1@two" {
+		t.Fatalf("unexpected clones: %v", clones)
+	}
+
+	if err := z.RescanDatasets(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if c, err := z.FindDatasetByName("tank/restore"); err != nil || c.Origin != "tank/fs1@two" {
+		t.Errorf("unexpected origin: '%s' - %v", c.Origin, err)
+	}
+
+	// only clones from the own snapshots
+	if err := ds.DestroyClone(ctx, "tank/fs1/sub", []string{"-r"}); zfs.KindOf(err) != zfs.ErrorNotFound {
+		t.Errorf("destroy a dataset which is not a clone: %v", err)
+	}
+
+	if err := ds.DestroyClone(ctx, "tank/test", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the snapshot is no longer blocked
+	if err := ds.DestroySnapshot(ctx, "three", nil); err != nil {
+		t.Errorf("destroy snapshot after the clone was destroyed: %v", err)
+	}
+
+	// promote: the snapshots up to the origin are moved to the clone
+	if err := ds.PromoteClone(ctx, "tank/restore"); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.RescanDatasets(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	restore, _ := z.FindDatasetByName("tank/restore")
+	if snaps, _ := restore.ScanSnapshots(ctx); len(snaps) != 2 || snaps[0].Name != "two" {
+		t.Errorf("unexpected snapshots in the promoted clone: %v", snaps)
+	}
+	ds, _ = z.FindDatasetByName("tank/fs1")
+	if ds.Origin != "tank/restore@two" || restore.Origin != "" {
+		t.Errorf("unexpected origins after promote: '%s', '%s'", ds.Origin, restore.Origin)
+	}
+	if snaps, _ := ds.ScanSnapshots(ctx); len(snaps) != 0 {
+		t.Errorf("unexpected snapshots in the origin dataset: %v", snaps)
+	}
+}
+
+func TestEncryptedDataset(t *testing.T) {
+	ctx := context.Background()
+	z, fake, cleanup := newFakeZFS(t)
+	defer cleanup()
+
+	if _, err := fake.CreateEncryptedDataset("tank/home", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.RescanDatasets(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err := z.FindDatasetByName("tank/home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ds.Locked || !ds.IsEncrypted() || ds.KeyLocation != "prompt" {
+		t.Errorf("unexpected encrypted dataset: %+v", ds)
+	}
+
+	// paths under the locked dataset belong to the parent dataset
+	if parent, err := z.FindDatasetForPath(ds.MountPoint.Path + "/file"); err != nil || parent.Name != "tank" {
+		t.Errorf("unexpected dataset for a path in a locked dataset: %s - %v", parent.Name, err)
+	}
+
+	if err := ds.Mount(ctx); err == nil {
+		t.Error("locked dataset mounted")
+	}
+
+	if err := ds.LoadKey(ctx, "wrong"); zfs.KindOf(err) != zfs.ErrorPermissionDenied {
+		t.Errorf("unexpected error for a wrong passphrase: %v (kind: '%s')", err, zfs.KindOf(err))
+	}
+
+	if err := ds.LoadKey(ctx, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.Mount(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := z.RescanDatasets(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ds, _ := z.FindDatasetByName("tank/home"); ds.Locked || ds.KeyStatus != "available" {
+		t.Errorf("dataset still locked: %+v", ds)
+	}
+}
+
+func TestCheckReceiveTarget(t *testing.T) {
+	ctx := context.Background()
+	z, _, cleanup := newFakeZFS(t)
+	defer cleanup()
+
+	for _, target := range []string{"tank/restored", "tank/fs1/restored@snap"} {
+		if err := z.CheckReceiveTarget(ctx, target); err != nil {
+			t.Errorf("target: '%s' rejected - %v", target, err)
+		}
+	}
+
+	// existing datasets - like the served root - are never overwritten
+	for _, target := range []string{"", "tank", "tank/fs1", "tank/fs1@snap", "tank2/fs1", "backup/fs1"} {
+		if err := z.CheckReceiveTarget(ctx, target); err == nil {
+			t.Errorf("target: '%s' accepted", target)
+		}
+	}
+}
+
+func TestQuerySnapshots(t *testing.T) {
+	ctx := context.Background()
+	z, _, cleanup := newFakeZFS(t)
+	defer cleanup()
+
+	ds, _ := z.FindDatasetByName("tank/fs1")
+	for _, name := range []string{"daily-1", "daily-2", "hourly-1"} {
+		var props map[string]string
+		if name == "daily-2" {
+			props = map[string]string{"com.example:keep": "yes"}
+		}
+		if _, err := ds.CreateSnapshot(ctx, name, nil, props); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for query, expected := range map[string]int{
+		"":                                  3,
+		"name=daily-*":                      2,
+		"name=daily-* com.example:keep=yes": 1,
+		"com.example:keep!=yes":             2,
+	} {
+		q, err := zfs.ParseSnapshotQuery(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		snaps, err := ds.QuerySnapshots(ctx, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(snaps) != expected {
+			t.Errorf("query: '%s' - %d snapshots found - expected: %d", query, len(snaps), expected)
+		}
+	}
+}
+
+func TestDestroyCloneInvalidatesCache(t *testing.T) {
+	ctx := context.Background()
+	z, fake, cleanup := newFakeZFS(t)
+	defer cleanup()
+
+	ds, _ := z.FindDatasetByName("tank/fs1")
+	if _, err := ds.CreateSnapshot(ctx, "one", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.CloneSnapshot(ctx, "one", "tank/restore", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// a snapshot in the clone and a dependent clone from this snapshot
+	for _, cmd := range []string{"snapshot tank/restore@s1", "clone tank/restore@s1 tank/dep", "snapshot tank/dep@d1"} {
+		if _, _, err := fake.Exec(ctx, cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := z.RescanDatasets(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// fill the cache
+	var clones []zfs.Dataset
+	for _, name := range []string{"tank/restore", "tank/dep"} {
+		d, _ := z.FindDatasetByName(name)
+		if snaps, _ := d.ScanSnapshots(ctx); len(snaps) != 1 {
+			t.Fatalf("%d snapshots in: %s found - expected 1", len(snaps), name)
+		}
+		clones = append(clones, d)
+	}
+
+	if err := ds.DestroyClone(ctx, "tank/restore", []string{"-R"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// recreate the datasets without snapshots - a rescan would drop the whole cache
+	for _, d := range clones {
+		if _, err := fake.CreateDataset(d.Name); err != nil {
+			t.Fatal(err)
+		}
+		if snaps, _ := d.ScanSnapshots(ctx); len(snaps) != 0 {
+			t.Errorf("stale snapshots in: %s - %v", d.Name, snaps)
+		}
+	}
+}
+
+func TestConcurrentRescan(t *testing.T) {
+	ctx := context.Background()
+	z, _, cleanup := newFakeZFS(t)
+	defer cleanup()
+
+	// run with '-race' to detect unguarded dataset accesses
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			if err := z.RescanDatasets(ctx); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		if _, err := z.FindDatasetByName("tank/fs1"); err != nil {
+			t.Error(err)
+		}
+	}
+	<-done
+}